@@ -0,0 +1,232 @@
+// Package catalog is a client for the Backstage catalog API.
+//
+// A Client is created with NewClient and a set of options:
+//
+//	client, err := catalog.NewClient("https://backstage.example.com",
+//		catalog.WithToken(os.Getenv("BACKSTAGE_TOKEN")),
+//	)
+//	entities, err := client.QueryEntities(ctx, catalog.Query{
+//		Filter: []string{"kind=component"},
+//	})
+package catalog
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Client talks to the catalog API of a single Backstage instance.
+type Client struct {
+	baseURL        string
+	token          string
+	tlsConfig      *tls.Config
+	httpClient     *http.Client
+	retry          RetryPolicy
+	requestTimeout time.Duration
+	trace          *tracer
+}
+
+// Option configures a Client.
+type Option func(*Client) error
+
+// WithToken authenticates requests with a bearer token.
+func WithToken(token string) Option {
+	return func(c *Client) error {
+		c.token = token
+		return nil
+	}
+}
+
+// WithTLSConfig sets the TLS configuration used to reach Backstage. It is
+// ignored when WithHTTPClient is also given.
+func WithTLSConfig(config *tls.Config) Option {
+	return func(c *Client) error {
+		c.tlsConfig = config
+		return nil
+	}
+}
+
+// WithClientCertificate authenticates with a TLS client certificate loaded
+// from a PEM encoded certificate and key pair.
+func WithClientCertificate(certFile, keyFile string) Option {
+	return func(c *Client) error {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("error loading TLS certificate: %w", err)
+		}
+		if c.tlsConfig == nil {
+			c.tlsConfig = &tls.Config{}
+		}
+		c.tlsConfig.Certificates = append(c.tlsConfig.Certificates, cert)
+		return nil
+	}
+}
+
+// WithHTTPClient sets the http.Client used to send requests.
+func WithHTTPClient(httpClient *http.Client) Option {
+	return func(c *Client) error {
+		c.httpClient = httpClient
+		return nil
+	}
+}
+
+// NewClient returns a Client for the Backstage instance at baseURL.
+func NewClient(baseURL string, opts ...Option) (*Client, error) {
+	if baseURL == "" {
+		return nil, fmt.Errorf("no baseUrl for the backstage instance provided")
+	}
+
+	c := &Client{baseURL: strings.TrimSuffix(baseURL, "/")}
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			return nil, err
+		}
+	}
+
+	if c.trace != nil {
+		c.trace.token = c.token
+	}
+
+	if c.httpClient == nil {
+		if c.tlsConfig != nil {
+			c.httpClient = &http.Client{
+				Transport: &http.Transport{
+					TLSClientConfig: c.tlsConfig,
+				},
+			}
+		} else {
+			c.httpClient = &http.Client{}
+		}
+	}
+
+	return c, nil
+}
+
+// BaseURL returns the base URL of the Backstage instance.
+func (c *Client) BaseURL() string {
+	return c.baseURL
+}
+
+// do sends a request to path, relative to the base URL, and returns the
+// response body of a successful call. Failed requests are retried according
+// to the retry policy of the client.
+func (c *Client) do(ctx context.Context, method, path string, body []byte) ([]byte, error) {
+	var respBody []byte
+	err := c.stream(ctx, method, path, body, func(r io.Reader) error {
+		var err error
+		respBody, err = io.ReadAll(r)
+		return err
+	})
+	return respBody, err
+}
+
+// stream is like do, but passes the body of a successful response to read
+// instead of buffering it. The body is closed once read returns.
+func (c *Client) stream(ctx context.Context, method, path string, body []byte, read func(io.Reader) error) error {
+	for retry := 0; ; retry++ {
+		err := c.send(ctx, method, path, body, read)
+		if err == nil || retry >= c.retry.MaxRetries || !retryable(ctx, err) {
+			return err
+		}
+
+		wait := c.retry.backoff(retry)
+		var respErr *ResponseError
+		if errors.As(err, &respErr) && respErr.RetryAfter > wait {
+			wait = respErr.RetryAfter
+		}
+
+		c.trace.printf("retry %d/%d in %s: %v", retry+1, c.retry.MaxRetries, wait.Round(time.Millisecond), err)
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// send makes a single attempt of a request.
+func (c *Client) send(ctx context.Context, method, path string, body []byte, read func(io.Reader) error) error {
+	if c.requestTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, c.requestTimeout)
+		defer cancel()
+	}
+
+	var reqBody io.Reader
+	if body != nil {
+		reqBody = bytes.NewReader(body)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reqBody)
+	if err != nil {
+		return fmt.Errorf("error creating request: %w", err)
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", c.token))
+	}
+
+	c.trace.request(req, body)
+	start := time.Now()
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.trace.response(req, nil, nil, time.Since(start), err)
+		return fmt.Errorf("%w: %w", ErrNetwork, err)
+	}
+	defer resp.Body.Close()
+
+	// Error responses, and every response when they are traced, are small
+	// enough to be buffered
+	if resp.StatusCode != http.StatusOK || c.trace.dumping() {
+		respBody, err := io.ReadAll(resp.Body)
+		c.trace.response(req, resp, respBody, time.Since(start), err)
+		if err != nil {
+			return fmt.Errorf("%w: error reading response: %w", ErrNetwork, err)
+		}
+		if resp.StatusCode != http.StatusOK {
+			respErr := newResponseError(method, req.URL.String(), resp.StatusCode, respBody)
+			respErr.RetryAfter = parseRetryAfter(resp.Header.Get("Retry-After"))
+			return respErr
+		}
+		return read(bytes.NewReader(respBody))
+	}
+
+	c.trace.response(req, resp, nil, time.Since(start), nil)
+	r := &bodyReader{r: resp.Body}
+	if err := read(r); err != nil {
+		if r.err != nil {
+			return fmt.Errorf("%w: error reading response: %w", ErrNetwork, r.err)
+		}
+		return err
+	}
+	return nil
+}
+
+// bodyReader records the error of reading a response body, to tell network
+// failures, which are retried, from decoding errors.
+type bodyReader struct {
+	r   io.Reader
+	err error
+}
+
+func (b *bodyReader) Read(p []byte) (int, error) {
+	n, err := b.r.Read(p)
+	if err != nil && err != io.EOF {
+		b.err = err
+	}
+	return n, err
+}
@@ -0,0 +1,196 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"iter"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Query selects entities from the entities/by-query endpoint.
+type Query struct {
+	// Filter holds conditions of the form key=value, or key to test that a
+	// field exists. Conditions on different keys must all match, conditions
+	// on the same key match if any of them does.
+	Filter []string
+	// Fields restricts the returned entities to the given dotted paths,
+	// e.g. metadata.name. All fields are returned when empty.
+	Fields []string
+	// FullTextTerm keeps the entities containing the term in one of
+	// FullTextFields, or in any field when FullTextFields is empty.
+	FullTextTerm   string
+	FullTextFields []string
+	// Order sorts the entities by the given fields, in order of precedence.
+	Order []OrderField
+	// Limit is the maximum number of entities returned, all of them when 0.
+	Limit int
+}
+
+// OrderField sorts entities by a dotted path, e.g. metadata.name.
+type OrderField struct {
+	Field      string
+	Descending bool
+}
+
+func (o OrderField) String() string {
+	if o.Descending {
+		return o.Field + ",desc"
+	}
+	return o.Field + ",asc"
+}
+
+func (q Query) values() url.Values {
+	values := url.Values{}
+	if len(q.Filter) > 0 {
+		values.Set("filter", strings.Join(q.Filter, ","))
+	}
+	if len(q.Fields) > 0 {
+		values.Set("fields", strings.Join(uniqueFields(q.Fields), ","))
+	}
+	if q.FullTextTerm != "" {
+		values.Set("fullTextFilterTerm", q.FullTextTerm)
+		if len(q.FullTextFields) > 0 {
+			values.Set("fullTextFilterFields", strings.Join(q.FullTextFields, ","))
+		}
+	}
+	for _, order := range q.Order {
+		values.Add("orderField", order.String())
+	}
+	if q.Limit > 0 {
+		values.Set("limit", strconv.Itoa(q.Limit))
+	}
+	return values
+}
+
+func uniqueFields(fields []string) []string {
+	seen := map[string]bool{}
+	var unique []string
+	for _, field := range fields {
+		if !seen[field] {
+			seen[field] = true
+			unique = append(unique, field)
+		}
+	}
+	return unique
+}
+
+// QueryEntities returns every entity matching the query, following the
+// pagination cursor until the last page or until Limit entities are read.
+func (c *Client) QueryEntities(ctx context.Context, query Query) ([]Entity, error) {
+	var entities []Entity
+	for entity, err := range c.Entities(ctx, query) {
+		if err != nil {
+			return nil, err
+		}
+		entities = append(entities, entity)
+	}
+	return entities, nil
+}
+
+// Entities returns an iterator over the entities matching the query. Pages
+// are fetched as the iteration reaches them, so only one page is held in
+// memory at a time. Iteration stops after an error, which is yielded along
+// with a zero Entity.
+func (c *Client) Entities(ctx context.Context, query Query) iter.Seq2[Entity, error] {
+	return func(yield func(Entity, error) bool) {
+		values := query.values()
+		read := 0
+
+		for page := 1; ; page++ {
+			var response entitiesResponse
+			err := c.stream(ctx, http.MethodGet, "/api/catalog/entities/by-query?"+values.Encode(), nil, func(r io.Reader) error {
+				if err := json.NewDecoder(r).Decode(&response); err != nil {
+					return fmt.Errorf("error unmarshalling JSON: %w", err)
+				}
+				return nil
+			})
+			if err != nil {
+				yield(Entity{}, err)
+				return
+			}
+
+			c.trace.printf("page %d: %d items (%d/%d), cursor %q, next cursor %q",
+				page, len(response.Items), read+len(response.Items), response.TotalItems, values.Get("cursor"), response.PageInfo.NextCursor)
+
+			for _, entity := range response.Items {
+				if query.Limit > 0 && read >= query.Limit {
+					return
+				}
+				read++
+				if !yield(entity, nil) {
+					return
+				}
+			}
+
+			if response.PageInfo.NextCursor == "" || (query.Limit > 0 && read >= query.Limit) {
+				return
+			}
+
+			// The cursor encodes the query, only the projection and the number
+			// of entities still wanted are sent along
+			for _, key := range []string{"filter", "fullTextFilterTerm", "fullTextFilterFields", "orderField"} {
+				values.Del(key)
+			}
+			if query.Limit > 0 {
+				values.Set("limit", strconv.Itoa(query.Limit-read))
+			}
+			values.Set("cursor", response.PageInfo.NextCursor)
+		}
+	}
+}
+
+// GetEntityByRef returns the entity with the given ref. An error matching
+// ErrNotFound is returned when the catalog doesn't have it.
+func (c *Client) GetEntityByRef(ctx context.Context, ref EntityRef) (Entity, error) {
+	path := fmt.Sprintf("/api/catalog/entities/by-name/%s/%s/%s",
+		url.PathEscape(ref.Kind), url.PathEscape(ref.Namespace), url.PathEscape(ref.Name))
+	body, err := c.do(ctx, http.MethodGet, path, nil)
+	if err != nil {
+		return Entity{}, err
+	}
+
+	var entity Entity
+	if err := json.Unmarshal(body, &entity); err != nil {
+		return Entity{}, fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	return entity, nil
+}
+
+// GetEntitiesByRefs returns the entities with the given refs, in the same
+// order. Refs that don't exist in the catalog yield a zero Entity, whose Kind
+// is empty. fields restricts the returned entities like Query.Fields.
+func (c *Client) GetEntitiesByRefs(ctx context.Context, refs []string, fields []string) ([]Entity, error) {
+	payload, err := json.Marshal(byRefsRequest{EntityRefs: refs, Fields: fields})
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling payload to JSON: %w", err)
+	}
+
+	body, err := c.do(ctx, http.MethodPost, "/api/catalog/entities/by-refs", payload)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Items []*Entity `json:"items"`
+	}
+
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+
+	found := 0
+	entities := make([]Entity, len(response.Items))
+	for i, item := range response.Items {
+		if item != nil {
+			entities[i] = *item
+			found++
+		}
+	}
+	c.trace.printf("by-refs: %d refs requested, %d found", len(refs), found)
+	return entities, nil
+}
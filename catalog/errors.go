@@ -0,0 +1,93 @@
+package catalog
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Errors returned by the Client can be tested with errors.Is against these
+// values to tell the class of failure apart.
+var (
+	// ErrUnauthorized means the catalog rejected the credentials (401 or 403).
+	ErrUnauthorized = errors.New("unauthorized")
+	// ErrNotFound means the requested entity or endpoint doesn't exist.
+	ErrNotFound = errors.New("not found")
+	// ErrServer means the catalog failed to handle the request (5xx).
+	ErrServer = errors.New("server error")
+	// ErrNetwork means the catalog could not be reached.
+	ErrNetwork = errors.New("network error")
+)
+
+// ResponseError is returned when the catalog answers with an unexpected
+// status. Name and Message are decoded from the standard Backstage error
+// body, {error:{name,message}, request, response:{statusCode}}, and are empty
+// when the body has another shape, e.g. when it comes from a proxy.
+type ResponseError struct {
+	Method     string
+	URL        string
+	StatusCode int
+	// Name is the error class reported by Backstage, e.g. NotFoundError.
+	Name string
+	// Message is the human readable error reported by Backstage.
+	Message string
+	// Body is the raw response body.
+	Body []byte
+	// RetryAfter is the wait asked by the Retry-After header, if any.
+	RetryAfter time.Duration
+}
+
+type errorResponse struct {
+	Error struct {
+		Name    string `json:"name"`
+		Message string `json:"message"`
+	} `json:"error"`
+	Response struct {
+		StatusCode int `json:"statusCode"`
+	} `json:"response"`
+}
+
+func newResponseError(method, url string, statusCode int, body []byte) *ResponseError {
+	e := &ResponseError{
+		Method:     method,
+		URL:        url,
+		StatusCode: statusCode,
+		Body:       body,
+	}
+
+	var envelope errorResponse
+	if err := json.Unmarshal(body, &envelope); err == nil {
+		e.Name = envelope.Error.Name
+		e.Message = envelope.Error.Message
+	}
+	return e
+}
+
+func (e *ResponseError) Error() string {
+	status := fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	switch {
+	case e.Name != "" && e.Message != "":
+		return fmt.Sprintf("%s: %s (%s)", e.Name, e.Message, status)
+	case e.Message != "":
+		return fmt.Sprintf("%s (%s)", e.Message, status)
+	case len(strings.TrimSpace(string(e.Body))) > 0 && len(e.Body) <= 200:
+		return fmt.Sprintf("%s %s: %s: %s", e.Method, e.URL, status, strings.TrimSpace(string(e.Body)))
+	}
+	return fmt.Sprintf("%s %s: %s", e.Method, e.URL, status)
+}
+
+// Is reports whether the status code belongs to the class of target.
+func (e *ResponseError) Is(target error) bool {
+	switch target {
+	case ErrUnauthorized:
+		return e.StatusCode == http.StatusUnauthorized || e.StatusCode == http.StatusForbidden
+	case ErrNotFound:
+		return e.StatusCode == http.StatusNotFound
+	case ErrServer:
+		return e.StatusCode >= 500
+	}
+	return false
+}
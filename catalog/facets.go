@@ -0,0 +1,40 @@
+package catalog
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// FacetValue is a distinct value of a field along with the number of
+// entities that have it.
+type FacetValue struct {
+	Value string `json:"value"`
+	Count int    `json:"count"`
+}
+
+// Facets returns the distinct values of the facet, a dotted field path such
+// as kind or spec.lifecycle, among the entities matching filter.
+func (c *Client) Facets(ctx context.Context, facet string, filter []string) ([]FacetValue, error) {
+	values := url.Values{}
+	values.Set("facet", facet)
+	if len(filter) > 0 {
+		values.Set("filter", strings.Join(filter, ","))
+	}
+
+	body, err := c.do(ctx, http.MethodGet, "/api/catalog/entity-facets?"+values.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Facets map[string][]FacetValue `json:"facets"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	return response.Facets[facet], nil
+}
@@ -0,0 +1,114 @@
+package catalog
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// DefaultNamespace is the namespace of entities that don't set one.
+const DefaultNamespace = "default"
+
+// ErrInvalidRef is returned when an entity ref can't be parsed or breaks the
+// naming rules of Backstage.
+var ErrInvalidRef = errors.New("invalid entity ref")
+
+// Character rules of the Backstage entity naming spec.
+var (
+	kindPattern      = regexp.MustCompile(`^[a-zA-Z][a-z0-9A-Z]*$`)
+	namespacePattern = regexp.MustCompile(`^[a-z0-9]+(?:-+[a-z0-9]+)*$`)
+	namePattern      = regexp.MustCompile(`^([A-Za-z0-9][-A-Za-z0-9_.]*)?[A-Za-z0-9]$`)
+)
+
+const maxRefPartLength = 63
+
+// EntityRef identifies an entity by kind, namespace and name. Refs are
+// written [kind:][namespace/]name and compared case insensitively.
+type EntityRef struct {
+	Kind      string
+	Namespace string
+	Name      string
+}
+
+// ParseEntityRef parses a ref of the form [kind:][namespace/]name. The kind
+// and namespace are taken from defaults when the ref omits them, the
+// namespace falling back to DefaultNamespace. The result is validated.
+func ParseEntityRef(ref string, defaults EntityRef) (EntityRef, error) {
+	parsed := defaults
+	rest := strings.TrimSpace(ref)
+
+	if kind, after, found := strings.Cut(rest, ":"); found {
+		parsed.Kind = kind
+		rest = after
+	}
+	if namespace, name, found := strings.Cut(rest, "/"); found {
+		parsed.Namespace = namespace
+		rest = name
+	}
+	parsed.Name = rest
+
+	if parsed.Namespace == "" {
+		parsed.Namespace = DefaultNamespace
+	}
+	if parsed.Kind == "" {
+		return EntityRef{}, fmt.Errorf("%w: %q has no kind, expected {kind}:{namespace}/{name}", ErrInvalidRef, ref)
+	}
+	if err := parsed.Validate(); err != nil {
+		return EntityRef{}, fmt.Errorf("%w (in %q)", err, ref)
+	}
+	return parsed, nil
+}
+
+// Validate checks the kind, namespace and name against the character rules
+// of Backstage. The namespace is compared case insensitively.
+func (r EntityRef) Validate() error {
+	if len(r.Kind) > maxRefPartLength || !kindPattern.MatchString(r.Kind) {
+		return fmt.Errorf("%w: kind %q must start with a letter followed by letters and digits, at most %d characters", ErrInvalidRef, r.Kind, maxRefPartLength)
+	}
+	if len(r.Namespace) > maxRefPartLength || !namespacePattern.MatchString(strings.ToLower(r.Namespace)) {
+		return fmt.Errorf("%w: namespace %q must be sequences of [a-z0-9] separated by '-', at most %d characters", ErrInvalidRef, r.Namespace, maxRefPartLength)
+	}
+	if len(r.Name) > maxRefPartLength || !namePattern.MatchString(r.Name) {
+		return fmt.Errorf("%w: name %q must be sequences of [a-zA-Z0-9] separated by any of [-_.], at most %d characters", ErrInvalidRef, r.Name, maxRefPartLength)
+	}
+	return nil
+}
+
+// String returns the full form kind:namespace/name, with the kind and
+// namespace lowercased as in the relations returned by the catalog.
+func (r EntityRef) String() string {
+	namespace := r.Namespace
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	return fmt.Sprintf("%s:%s/%s", strings.ToLower(r.Kind), strings.ToLower(namespace), r.Name)
+}
+
+// Compact returns the ref without the namespace when it is the default one.
+func (r EntityRef) Compact() string {
+	if r.Namespace == "" || strings.EqualFold(r.Namespace, DefaultNamespace) {
+		return fmt.Sprintf("%s:%s", strings.ToLower(r.Kind), r.Name)
+	}
+	return r.String()
+}
+
+// Equal reports whether both refs point to the same entity.
+func (r EntityRef) Equal(other EntityRef) bool {
+	return r.Key() == other.Key()
+}
+
+// Key returns the ref lowercased, the same for every spelling of the ref,
+// to index entities by ref.
+func (r EntityRef) Key() string {
+	return strings.ToLower(r.String())
+}
+
+// Ref returns the ref of the entity.
+func (e Entity) Ref() EntityRef {
+	namespace := e.Metadata.Namespace
+	if namespace == "" {
+		namespace = DefaultNamespace
+	}
+	return EntityRef{Kind: e.Kind, Namespace: namespace, Name: e.Metadata.Name}
+}
@@ -0,0 +1,106 @@
+package catalog
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseEntityRef(t *testing.T) {
+	long := strings.Repeat("a", maxRefPartLength)
+
+	tests := []struct {
+		ref      string
+		defaults EntityRef
+		want     EntityRef
+		wantErr  bool
+	}{
+		{ref: "component:default/payments", want: EntityRef{Kind: "component", Namespace: "default", Name: "payments"}},
+		{ref: "component:payments", want: EntityRef{Kind: "component", Namespace: "default", Name: "payments"}},
+		{ref: "payments", defaults: EntityRef{Kind: "Component"}, want: EntityRef{Kind: "Component", Namespace: "default", Name: "payments"}},
+		{ref: "shop/web", defaults: EntityRef{Kind: "Component"}, want: EntityRef{Kind: "Component", Namespace: "shop", Name: "web"}},
+		{ref: "web", defaults: EntityRef{Kind: "Component", Namespace: "shop"}, want: EntityRef{Kind: "Component", Namespace: "shop", Name: "web"}},
+		{ref: "group:default/team-a", defaults: EntityRef{Kind: "Component"}, want: EntityRef{Kind: "group", Namespace: "default", Name: "team-a"}},
+		{ref: "  api:payments-api  ", want: EntityRef{Kind: "api", Namespace: "default", Name: "payments-api"}},
+		{ref: "Component:Shop/Payments_v2.1", want: EntityRef{Kind: "Component", Namespace: "Shop", Name: "Payments_v2.1"}},
+		{ref: "component:" + long + "/" + long, want: EntityRef{Kind: "component", Namespace: long, Name: long}},
+		{ref: long + ":payments", want: EntityRef{Kind: long, Namespace: "default", Name: "payments"}},
+		{ref: "payments", wantErr: true},
+		{ref: "component:", wantErr: true},
+		{ref: "component:default/", wantErr: true},
+		{ref: "a/b/c", defaults: EntityRef{Kind: "Component"}, wantErr: true},
+		{ref: "component:a/b/c", wantErr: true},
+		{ref: "component:" + long + "a", wantErr: true},
+		{ref: "component:" + long + "a/payments", wantErr: true},
+		{ref: long + "a:payments", wantErr: true},
+		{ref: "component:-payments", wantErr: true},
+		{ref: "component:payments-", wantErr: true},
+		{ref: "component:pay ments", wantErr: true},
+		{ref: "component:shop_eu/payments", wantErr: true},
+		{ref: "component:shop--eu/payments", want: EntityRef{Kind: "component", Namespace: "shop--eu", Name: "payments"}},
+		{ref: "1component:payments", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ref, func(t *testing.T) {
+			got, err := ParseEntityRef(tt.ref, tt.defaults)
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidRef) {
+					t.Errorf("ParseEntityRef(%q) = %v, %v, want ErrInvalidRef", tt.ref, got, err)
+				}
+				return
+			}
+			if err != nil || got != tt.want {
+				t.Errorf("ParseEntityRef(%q) = %v, %v, want %v", tt.ref, got, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestEntityRefString(t *testing.T) {
+	tests := []struct {
+		ref     EntityRef
+		string  string
+		compact string
+	}{
+		{EntityRef{Kind: "Component", Namespace: "default", Name: "Payments"}, "component:default/Payments", "component:Payments"},
+		{EntityRef{Kind: "Component", Name: "payments"}, "component:default/payments", "component:payments"},
+		{EntityRef{Kind: "Group", Namespace: "Default", Name: "team-a"}, "group:default/team-a", "group:team-a"},
+		{EntityRef{Kind: "API", Namespace: "Shop", Name: "orders"}, "api:shop/orders", "api:shop/orders"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.string, func(t *testing.T) {
+			if got := tt.ref.String(); got != tt.string {
+				t.Errorf("String() = %q, want %q", got, tt.string)
+			}
+			if got := tt.ref.Compact(); got != tt.compact {
+				t.Errorf("Compact() = %q, want %q", got, tt.compact)
+			}
+		})
+	}
+}
+
+func TestEntityRefEqual(t *testing.T) {
+	payments := EntityRef{Kind: "Component", Namespace: "default", Name: "payments"}
+	tests := []struct {
+		name  string
+		other EntityRef
+		want  bool
+	}{
+		{"same", payments, true},
+		{"other case", EntityRef{Kind: "component", Namespace: "Default", Name: "Payments"}, true},
+		{"default namespace omitted", EntityRef{Kind: "COMPONENT", Name: "PAYMENTS"}, true},
+		{"other kind", EntityRef{Kind: "API", Namespace: "default", Name: "payments"}, false},
+		{"other namespace", EntityRef{Kind: "Component", Namespace: "shop", Name: "payments"}, false},
+		{"other name", EntityRef{Kind: "Component", Namespace: "default", Name: "ledger"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := payments.Equal(tt.other); got != tt.want {
+				t.Errorf("Equal(%v) = %v, want %v", tt.other, got, tt.want)
+			}
+			if got := payments.Key() == tt.other.Key(); got != tt.want {
+				t.Errorf("Key() of %v equal = %v, want %v", tt.other, got, tt.want)
+			}
+		})
+	}
+}
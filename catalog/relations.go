@@ -0,0 +1,76 @@
+package catalog
+
+// Well-known relation types. Each relation is stored on both entities, as
+// the type on the source and its inverse on the target.
+const (
+	RelationOwnedBy       = "ownedBy"
+	RelationOwnerOf       = "ownerOf"
+	RelationPartOf        = "partOf"
+	RelationHasPart       = "hasPart"
+	RelationDependsOn     = "dependsOn"
+	RelationDependencyOf  = "dependencyOf"
+	RelationConsumesAPI   = "consumesApi"
+	RelationAPIConsumedBy = "apiConsumedBy"
+	RelationProvidesAPI   = "providesApi"
+	RelationAPIProvidedBy = "apiProvidedBy"
+	RelationMemberOf      = "memberOf"
+	RelationHasMember     = "hasMember"
+	RelationChildOf       = "childOf"
+	RelationParentOf      = "parentOf"
+)
+
+// inverseRelations maps the type of a relation declared by an entity to the
+// type the catalog stores on its target.
+var inverseRelations = map[string]string{
+	RelationOwnedBy:     RelationOwnerOf,
+	RelationPartOf:      RelationHasPart,
+	RelationDependsOn:   RelationDependencyOf,
+	RelationConsumesAPI: RelationAPIConsumedBy,
+	RelationProvidesAPI: RelationAPIProvidedBy,
+	RelationMemberOf:    RelationHasMember,
+	RelationChildOf:     RelationParentOf,
+}
+
+// InverseRelation returns the type of the relation stored on the target of a
+// relation of the given type, e.g. ownerOf for ownedBy and the other way
+// around, or an empty string for unknown types.
+func InverseRelation(relationType string) string {
+	if inverse, ok := inverseRelations[relationType]; ok {
+		return inverse
+	}
+	for declared, inverse := range inverseRelations {
+		if inverse == relationType {
+			return declared
+		}
+	}
+	return ""
+}
+
+// IsInverseRelation reports whether the relation type is the inverse of one
+// declared in entity specs, e.g. ownerOf or dependencyOf. Such relations
+// point back at the entities that declared them.
+func IsInverseRelation(relationType string) bool {
+	for _, inverse := range inverseRelations {
+		if inverse == relationType {
+			return true
+		}
+	}
+	return false
+}
+
+// RelationsOfType returns the target refs of the entity's relations of the
+// given types, in the order they are listed.
+func (e Entity) RelationsOfType(types ...string) []EntityRef {
+	var refs []EntityRef
+	for _, relation := range e.Relations {
+		for _, relationType := range types {
+			if relation.Type == relationType {
+				if ref, err := ParseEntityRef(relation.TargetRef, EntityRef{}); err == nil {
+					refs = append(refs, ref)
+				}
+				break
+			}
+		}
+	}
+	return refs
+}
@@ -0,0 +1,94 @@
+package catalog
+
+import (
+	"context"
+	"errors"
+	"math/rand/v2"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// RetryPolicy controls how failed requests are retried. Requests are retried
+// on connection errors, on 429 Too Many Requests and on 5xx responses, waiting
+// a random duration up to an exponentially growing backoff between attempts,
+// or the duration asked by a Retry-After header when it is longer.
+type RetryPolicy struct {
+	// MaxRetries is the number of retries after the first attempt.
+	MaxRetries int
+	// MinBackoff is the backoff before the first retry, doubled for each
+	// following one.
+	MinBackoff time.Duration
+	// MaxBackoff caps the exponential backoff.
+	MaxBackoff time.Duration
+}
+
+// DefaultRetryPolicy is used by the CLI when no other policy is configured.
+var DefaultRetryPolicy = RetryPolicy{
+	MaxRetries: 3,
+	MinBackoff: 500 * time.Millisecond,
+	MaxBackoff: 10 * time.Second,
+}
+
+// WithRetry retries failed requests according to policy. Without this option
+// requests are not retried.
+func WithRetry(policy RetryPolicy) Option {
+	return func(c *Client) error {
+		c.retry = policy
+		return nil
+	}
+}
+
+// WithRequestTimeout limits the duration of every single request, retries
+// included. Use a context deadline to limit the duration of a whole call.
+func WithRequestTimeout(timeout time.Duration) Option {
+	return func(c *Client) error {
+		c.requestTimeout = timeout
+		return nil
+	}
+}
+
+// backoff returns the wait before the given retry, counting from 0, using
+// full jitter to spread the retries of concurrent clients.
+func (p RetryPolicy) backoff(retry int) time.Duration {
+	backoff := p.MinBackoff
+	for i := 0; i < retry && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if p.MaxBackoff > 0 && backoff > p.MaxBackoff {
+		backoff = p.MaxBackoff
+	}
+	if backoff <= 0 {
+		return 0
+	}
+	return rand.N(backoff) + 1
+}
+
+// retryable reports whether a request that failed with err may succeed if
+// sent again. Errors caused by ctx itself, e.g. the overall timeout, are final.
+func retryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var respErr *ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.StatusCode == http.StatusTooManyRequests || respErr.StatusCode >= 500
+	}
+	return errors.Is(err, ErrNetwork)
+}
+
+// parseRetryAfter decodes a Retry-After header given either in seconds or as
+// an HTTP date.
+func parseRetryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds > 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		return time.Until(date)
+	}
+	return 0
+}
@@ -0,0 +1,163 @@
+package catalog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// ComponentSpec is the spec of a Component entity.
+type ComponentSpec struct {
+	Type           string   `json:"type"`
+	Lifecycle      string   `json:"lifecycle"`
+	Owner          string   `json:"owner"`
+	System         string   `json:"system,omitempty"`
+	SubcomponentOf string   `json:"subcomponentOf,omitempty"`
+	ProvidesApis   []string `json:"providesApis,omitempty"`
+	ConsumesApis   []string `json:"consumesApis,omitempty"`
+	DependsOn      []string `json:"dependsOn,omitempty"`
+	DependencyOf   []string `json:"dependencyOf,omitempty"`
+}
+
+// APISpec is the spec of an API entity.
+type APISpec struct {
+	Type       string `json:"type"`
+	Lifecycle  string `json:"lifecycle"`
+	Owner      string `json:"owner"`
+	System     string `json:"system,omitempty"`
+	Definition string `json:"definition"`
+}
+
+// ResourceSpec is the spec of a Resource entity.
+type ResourceSpec struct {
+	Type         string   `json:"type"`
+	Owner        string   `json:"owner"`
+	System       string   `json:"system,omitempty"`
+	DependsOn    []string `json:"dependsOn,omitempty"`
+	DependencyOf []string `json:"dependencyOf,omitempty"`
+}
+
+// SystemSpec is the spec of a System entity.
+type SystemSpec struct {
+	Owner  string `json:"owner"`
+	Domain string `json:"domain,omitempty"`
+	Type   string `json:"type,omitempty"`
+}
+
+// DomainSpec is the spec of a Domain entity.
+type DomainSpec struct {
+	Owner       string `json:"owner"`
+	SubdomainOf string `json:"subdomainOf,omitempty"`
+	Type        string `json:"type,omitempty"`
+}
+
+// GroupSpec is the spec of a Group entity.
+type GroupSpec struct {
+	Type     string   `json:"type"`
+	Profile  *Profile `json:"profile,omitempty"`
+	Parent   string   `json:"parent,omitempty"`
+	Children []string `json:"children"`
+	Members  []string `json:"members,omitempty"`
+}
+
+// UserSpec is the spec of a User entity.
+type UserSpec struct {
+	Profile  *Profile `json:"profile,omitempty"`
+	MemberOf []string `json:"memberOf"`
+}
+
+// Profile is the profile of a Group or User.
+type Profile struct {
+	DisplayName string `json:"displayName,omitempty"`
+	Email       string `json:"email,omitempty"`
+	Picture     string `json:"picture,omitempty"`
+}
+
+// LocationSpec is the spec of a Location entity.
+type LocationSpec struct {
+	Type     string   `json:"type,omitempty"`
+	Target   string   `json:"target,omitempty"`
+	Targets  []string `json:"targets,omitempty"`
+	Presence string   `json:"presence,omitempty"`
+}
+
+// TemplateSpec is the spec of a scaffolder Template entity. Parameters and
+// steps are left in Entity.Spec.
+type TemplateSpec struct {
+	Type      string `json:"type"`
+	Owner     string `json:"owner,omitempty"`
+	Lifecycle string `json:"lifecycle,omitempty"`
+}
+
+// DecodeSpec decodes the spec of the entity into v, e.g. a *ComponentSpec.
+func (e Entity) DecodeSpec(v interface{}) error {
+	data, err := json.Marshal(e.Spec)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return fmt.Errorf("error decoding spec of %s: %w", e.Ref(), err)
+	}
+	return nil
+}
+
+func decodeSpecOfKind[T any](e Entity, kind string) (T, error) {
+	var spec T
+	if !strings.EqualFold(e.Kind, kind) {
+		return spec, fmt.Errorf("%s is not of kind %s", e.Ref(), kind)
+	}
+	err := e.DecodeSpec(&spec)
+	return spec, err
+}
+
+// ComponentSpec returns the spec of a Component entity.
+func (e Entity) ComponentSpec() (ComponentSpec, error) {
+	return decodeSpecOfKind[ComponentSpec](e, "Component")
+}
+
+// APISpec returns the spec of an API entity.
+func (e Entity) APISpec() (APISpec, error) {
+	return decodeSpecOfKind[APISpec](e, "API")
+}
+
+// ResourceSpec returns the spec of a Resource entity.
+func (e Entity) ResourceSpec() (ResourceSpec, error) {
+	return decodeSpecOfKind[ResourceSpec](e, "Resource")
+}
+
+// SystemSpec returns the spec of a System entity.
+func (e Entity) SystemSpec() (SystemSpec, error) {
+	return decodeSpecOfKind[SystemSpec](e, "System")
+}
+
+// DomainSpec returns the spec of a Domain entity.
+func (e Entity) DomainSpec() (DomainSpec, error) {
+	return decodeSpecOfKind[DomainSpec](e, "Domain")
+}
+
+// GroupSpec returns the spec of a Group entity.
+func (e Entity) GroupSpec() (GroupSpec, error) {
+	return decodeSpecOfKind[GroupSpec](e, "Group")
+}
+
+// UserSpec returns the spec of a User entity.
+func (e Entity) UserSpec() (UserSpec, error) {
+	return decodeSpecOfKind[UserSpec](e, "User")
+}
+
+// LocationSpec returns the spec of a Location entity.
+func (e Entity) LocationSpec() (LocationSpec, error) {
+	return decodeSpecOfKind[LocationSpec](e, "Location")
+}
+
+// TemplateSpec returns the spec of a Template entity.
+func (e Entity) TemplateSpec() (TemplateSpec, error) {
+	return decodeSpecOfKind[TemplateSpec](e, "Template")
+}
+
+// SpecString returns the string field of the spec with the given name, e.g.
+// owner or lifecycle, or an empty string if the entity doesn't have it.
+func (e Entity) SpecString(name string) string {
+	value, _ := e.Spec[name].(string)
+	return value
+}
@@ -0,0 +1,100 @@
+package catalog
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithTrace writes a line to w for every request sent: method, URL, status
+// and latency, along with the page cursor and item count of paginated
+// queries. With dumpBodies set the headers and bodies of requests and
+// responses are written as well, with the Authorization header and anything
+// that looks like a token redacted.
+func WithTrace(w io.Writer, dumpBodies bool) Option {
+	return func(c *Client) error {
+		c.trace = &tracer{w: w, dumpBodies: dumpBodies}
+		return nil
+	}
+}
+
+const redacted = "[REDACTED]"
+
+// secretPattern matches JSON string fields whose name suggests a credential.
+var secretPattern = regexp.MustCompile(`(?i)("[^"]*(token|secret|password|authorization|credential)[^"]*"\s*:\s*)"[^"]*"`)
+
+type tracer struct {
+	mu         sync.Mutex
+	w          io.Writer
+	dumpBodies bool
+	token      string
+}
+
+// printf writes a trace line, it is a no-op on a nil tracer.
+func (t *tracer) printf(format string, args ...interface{}) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.w, format+"\n", args...)
+}
+
+// dumping reports whether bodies are traced.
+func (t *tracer) dumping() bool {
+	return t != nil && t.dumpBodies
+}
+
+func (t *tracer) request(req *http.Request, body []byte) {
+	if t == nil || !t.dumpBodies {
+		return
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "> %s %s", req.Method, req.URL)
+	for name, values := range req.Header {
+		for _, value := range values {
+			if strings.EqualFold(name, "Authorization") {
+				value = redactAuthorization(value)
+			}
+			fmt.Fprintf(&b, "\n> %s: %s", name, value)
+		}
+	}
+	if len(body) > 0 {
+		fmt.Fprintf(&b, "\n%s", t.redact(body))
+	}
+	t.printf("%s", b.String())
+}
+
+func (t *tracer) response(req *http.Request, resp *http.Response, body []byte, latency time.Duration, err error) {
+	if t == nil {
+		return
+	}
+	if err != nil {
+		t.printf("%s %s failed after %s: %v", req.Method, req.URL, latency.Round(time.Millisecond), err)
+		return
+	}
+	t.printf("%s %s %s in %s", req.Method, req.URL, resp.Status, latency.Round(time.Millisecond))
+	if t.dumpBodies && len(body) > 0 {
+		t.printf("< %s", strings.TrimRight(t.redact(body), "\n"))
+	}
+}
+
+// redact hides secrets in a request or response body.
+func (t *tracer) redact(body []byte) string {
+	s := secretPattern.ReplaceAllString(string(body), `${1}"`+redacted+`"`)
+	if t.token != "" {
+		s = strings.ReplaceAll(s, t.token, redacted)
+	}
+	return s
+}
+
+func redactAuthorization(value string) string {
+	if scheme, _, found := strings.Cut(value, " "); found {
+		return scheme + " " + redacted
+	}
+	return redacted
+}
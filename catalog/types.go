@@ -0,0 +1,271 @@
+package catalog
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+)
+
+type entitiesResponse struct {
+	Items    []Entity `json:"items"`
+	PageInfo struct {
+		NextCursor string `json:"nextCursor"`
+	} `json:"pageInfo"`
+	TotalItems int `json:"totalItems"`
+}
+
+// Entity is the envelope shared by every kind of catalog entity. Fields that
+// are not modeled are kept in the Extra field of the envelope and of each
+// nested type, so an entity decoded from JSON or YAML encodes back to the same
+// document.
+type Entity struct {
+	ApiVersion string                 `json:"apiVersion,omitempty" yaml:"apiVersion,omitempty"`
+	Kind       string                 `json:"kind,omitempty" yaml:"kind,omitempty"`
+	Metadata   Metadata               `json:"metadata" yaml:"metadata"`
+	Spec       map[string]interface{} `json:"spec,omitempty" yaml:"spec,omitempty"`
+	Relations  []Relation             `json:"relations,omitempty" yaml:"relations,omitempty"`
+	Status     *Status                `json:"status,omitempty" yaml:"status,omitempty"`
+	Extra      map[string]interface{} `json:"-" yaml:",inline"`
+}
+
+// Metadata is the metadata block of an entity.
+type Metadata struct {
+	UID         string                 `json:"uid,omitempty" yaml:"uid,omitempty"`
+	Etag        string                 `json:"etag,omitempty" yaml:"etag,omitempty"`
+	Name        string                 `json:"name,omitempty" yaml:"name,omitempty"`
+	Namespace   string                 `json:"namespace,omitempty" yaml:"namespace,omitempty"`
+	Title       string                 `json:"title,omitempty" yaml:"title,omitempty"`
+	Description string                 `json:"description,omitempty" yaml:"description,omitempty"`
+	Labels      map[string]string      `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Annotations map[string]string      `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+	Tags        []string               `json:"tags,omitempty" yaml:"tags,omitempty"`
+	Links       []Link                 `json:"links,omitempty" yaml:"links,omitempty"`
+	Extra       map[string]interface{} `json:"-" yaml:",inline"`
+}
+
+// Link is an external hyperlink related to an entity.
+type Link struct {
+	URL   string                 `json:"url" yaml:"url"`
+	Title string                 `json:"title,omitempty" yaml:"title,omitempty"`
+	Icon  string                 `json:"icon,omitempty" yaml:"icon,omitempty"`
+	Type  string                 `json:"type,omitempty" yaml:"type,omitempty"`
+	Extra map[string]interface{} `json:"-" yaml:",inline"`
+}
+
+// Relation is a directed relation from the entity to the target entity, e.g.
+// ownedBy or dependsOn. The catalog stores both directions of a relation, so
+// the target holds the inverse one, e.g. ownerOf or dependencyOf.
+type Relation struct {
+	Type      string                 `json:"type" yaml:"type"`
+	TargetRef string                 `json:"targetRef" yaml:"targetRef"`
+	Target    *RelationTarget        `json:"target,omitempty" yaml:"target,omitempty"`
+	Extra     map[string]interface{} `json:"-" yaml:",inline"`
+}
+
+// RelationTarget is the deprecated object form of Relation.TargetRef.
+type RelationTarget struct {
+	Kind      string                 `json:"kind" yaml:"kind"`
+	Namespace string                 `json:"namespace" yaml:"namespace"`
+	Name      string                 `json:"name" yaml:"name"`
+	Extra     map[string]interface{} `json:"-" yaml:",inline"`
+}
+
+// Status is the status block written by the catalog, holding among others
+// the errors raised while processing the entity.
+type Status struct {
+	Items []StatusItem           `json:"items,omitempty" yaml:"items,omitempty"`
+	Extra map[string]interface{} `json:"-" yaml:",inline"`
+}
+
+// StatusItem is a single status entry, e.g. a processing error.
+type StatusItem struct {
+	Type    string                 `json:"type" yaml:"type"`
+	Level   string                 `json:"level" yaml:"level"`
+	Message string                 `json:"message" yaml:"message"`
+	Error   *StatusError           `json:"error,omitempty" yaml:"error,omitempty"`
+	Extra   map[string]interface{} `json:"-" yaml:",inline"`
+}
+
+// StatusError is the serialized error of a status item.
+type StatusError struct {
+	Name    string                 `json:"name" yaml:"name"`
+	Message string                 `json:"message" yaml:"message"`
+	Stack   string                 `json:"stack,omitempty" yaml:"stack,omitempty"`
+	Extra   map[string]interface{} `json:"-" yaml:",inline"`
+}
+
+type byRefsRequest struct {
+	EntityRefs []string `json:"entityRefs"`
+	Fields     []string `json:"fields,omitempty"`
+}
+
+func (e *Entity) UnmarshalJSON(data []byte) error {
+	type plain Entity
+	if err := json.Unmarshal(data, (*plain)(e)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, "apiVersion", "kind", "metadata", "spec", "relations", "status")
+	e.Extra = extra
+	return err
+}
+
+func (e Entity) MarshalJSON() ([]byte, error) {
+	type plain Entity
+	return marshalWithExtra(plain(e), e.Extra)
+}
+
+func (m *Metadata) UnmarshalJSON(data []byte) error {
+	type plain Metadata
+	if err := json.Unmarshal(data, (*plain)(m)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, "uid", "etag", "name", "namespace", "title", "description", "labels", "annotations", "tags", "links")
+	m.Extra = extra
+	return err
+}
+
+func (m Metadata) MarshalJSON() ([]byte, error) {
+	type plain Metadata
+	return marshalWithExtra(plain(m), m.Extra)
+}
+
+func (l *Link) UnmarshalJSON(data []byte) error {
+	type plain Link
+	if err := json.Unmarshal(data, (*plain)(l)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, "url", "title", "icon", "type")
+	l.Extra = extra
+	return err
+}
+
+func (l Link) MarshalJSON() ([]byte, error) {
+	type plain Link
+	return marshalWithExtra(plain(l), l.Extra)
+}
+
+func (r *Relation) UnmarshalJSON(data []byte) error {
+	type plain Relation
+	if err := json.Unmarshal(data, (*plain)(r)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, "type", "targetRef", "target")
+	r.Extra = extra
+	return err
+}
+
+func (r Relation) MarshalJSON() ([]byte, error) {
+	type plain Relation
+	return marshalWithExtra(plain(r), r.Extra)
+}
+
+func (t *RelationTarget) UnmarshalJSON(data []byte) error {
+	type plain RelationTarget
+	if err := json.Unmarshal(data, (*plain)(t)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, "kind", "namespace", "name")
+	t.Extra = extra
+	return err
+}
+
+func (t RelationTarget) MarshalJSON() ([]byte, error) {
+	type plain RelationTarget
+	return marshalWithExtra(plain(t), t.Extra)
+}
+
+func (s *Status) UnmarshalJSON(data []byte) error {
+	type plain Status
+	if err := json.Unmarshal(data, (*plain)(s)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, "items")
+	s.Extra = extra
+	return err
+}
+
+func (s Status) MarshalJSON() ([]byte, error) {
+	type plain Status
+	return marshalWithExtra(plain(s), s.Extra)
+}
+
+func (i *StatusItem) UnmarshalJSON(data []byte) error {
+	type plain StatusItem
+	if err := json.Unmarshal(data, (*plain)(i)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, "type", "level", "message", "error")
+	i.Extra = extra
+	return err
+}
+
+func (i StatusItem) MarshalJSON() ([]byte, error) {
+	type plain StatusItem
+	return marshalWithExtra(plain(i), i.Extra)
+}
+
+func (e *StatusError) UnmarshalJSON(data []byte) error {
+	type plain StatusError
+	if err := json.Unmarshal(data, (*plain)(e)); err != nil {
+		return err
+	}
+	extra, err := unknownFields(data, "name", "message", "stack")
+	e.Extra = extra
+	return err
+}
+
+func (e StatusError) MarshalJSON() ([]byte, error) {
+	type plain StatusError
+	return marshalWithExtra(plain(e), e.Extra)
+}
+
+// unknownFields returns the fields of the JSON object data that are not in known.
+func unknownFields(data []byte, known ...string) (map[string]interface{}, error) {
+	var fields map[string]interface{}
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	for _, name := range known {
+		delete(fields, name)
+	}
+	if len(fields) == 0 {
+		return nil, nil
+	}
+	return fields, nil
+}
+
+// marshalWithExtra encodes v, a struct, followed by the extra fields in
+// alphabetical order.
+func marshalWithExtra(v interface{}, extra map[string]interface{}) ([]byte, error) {
+	data, err := json.Marshal(v)
+	if err != nil || len(extra) == 0 {
+		return data, err
+	}
+
+	names := make([]string, 0, len(extra))
+	for name := range extra {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+	buf.Write(data[:len(data)-1])
+	for _, name := range names {
+		key, err := json.Marshal(name)
+		if err != nil {
+			return nil, err
+		}
+		value, err := json.Marshal(extra[name])
+		if err != nil {
+			return nil, err
+		}
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(value)
+	}
+	buf.WriteByte('}')
+	return buf.Bytes(), nil
+}
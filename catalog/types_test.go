@@ -0,0 +1,58 @@
+package catalog
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestEntityKeepsUnknownFields(t *testing.T) {
+	const document = `{
+  "apiVersion": "backstage.io/v1alpha1",
+  "kind": "Component",
+  "metadata": {
+    "name": "payments",
+    "links": [{"url": "https://grafana.example.com", "type": "dashboard", "extra": "link"}],
+    "extra": "metadata"
+  },
+  "relations": [
+    {"type": "ownedBy", "targetRef": "group:default/team-a", "target": {"kind": "group", "namespace": "default", "name": "team-a", "extra": "target"}, "extra": "relation"}
+  ],
+  "status": {
+    "items": [
+      {"type": "backstage.io/catalog-processing", "level": "error", "message": "failed", "error": {"name": "InputError", "message": "bad", "extra": "error"}, "extra": "item"}
+    ],
+    "extra": "status"
+  },
+  "extra": "entity"
+}`
+
+	var entity Entity
+	if err := json.Unmarshal([]byte(document), &entity); err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, err := json.Marshal(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want, got interface{}
+	json.Unmarshal([]byte(document), &want)
+	json.Unmarshal(encoded, &got)
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("JSON round trip = %s, want %s", encoded, document)
+	}
+
+	data, err := yaml.Marshal(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, value := range []string{"entity", "metadata", "link", "relation", "target", "status", "item", "error"} {
+		if !strings.Contains(string(data), "extra: "+value+"\n") {
+			t.Errorf("YAML is missing extra: %s\n%s", value, data)
+		}
+	}
+}
@@ -1,17 +1,15 @@
 package cmd
 
 import (
-	"crypto/tls"
-	"encoding/json"
+	"context"
 	"fmt"
-	"net/http"
 	"os"
-	"path/filepath"
 
 	"github.com/spf13/cobra"
+	"github.com/vcaldaralo/backstagectl/catalog"
 )
 
-var client *http.Client
+var client *catalog.Client
 var (
 	baseUrl     string
 	token       string
@@ -26,141 +24,163 @@ type AuthConfig struct {
 	TLSKeyPath  string `json:"tls_key_path"`
 }
 
-func getHomeDir() string {
+func getHomeDir() (string, error) {
 	homeDir, err := os.UserHomeDir()
 	if err != nil {
-		fmt.Println("error getting home directory:", err)
-		return ""
+		return "", fmt.Errorf("error getting home directory: %w", err)
 	}
-	return homeDir
+	return homeDir, nil
 }
 
-func initAuth() {
-	// Load authentication details from file
-	authConfig := loadAuthConfig(filepath.Join(getHomeDir(), ".config/backstagectl/config.json"))
-	if authConfig != nil {
-		baseUrl = authConfig.BaseUrl
-		token = authConfig.Token
-		tlsCertPath = authConfig.TLSCertPath
-		tlsKeyPath = authConfig.TLSKeyPath
+// initAuth creates the catalog client for the selected context and applies
+// the overall timeout to the context of cmd.
+func initAuth(cmd *cobra.Command) error {
+	// Load authentication details for the selected context
+	path, err := configPath()
+	if err != nil {
+		return err
+	}
+	ctx, err := loadContext(path, contextName)
+	if err != nil {
+		return err
 	}
+	baseUrl = ctx.BaseUrl
+	token = ctx.Token
+	tlsCertPath = ctx.TLSCertPath
+	tlsKeyPath = ctx.TLSKeyPath
 
-	// Create TLS client if cert/key provided
+	// Only use token auth if cert/key not provided
+	var opts []catalog.Option
 	if tlsCertPath != "" && tlsKeyPath != "" {
-		cert, err := tls.LoadX509KeyPair(tlsCertPath, tlsKeyPath)
-		if err != nil {
-			fmt.Printf("error loading TLS certificate: %v\n", err)
-			return
-		}
+		opts = append(opts, catalog.WithClientCertificate(tlsCertPath, tlsKeyPath))
+	} else {
+		opts = append(opts, catalog.WithToken(token))
+	}
 
-		tlsConfig := &tls.Config{
-			Certificates: []tls.Certificate{cert},
-		}
+	settingOpts, err := requestOptions(ctx.Settings, cmd.Flags())
+	if err != nil {
+		return err
+	}
+	opts = append(opts, settingOpts...)
 
-		client = &http.Client{
-			Transport: &http.Transport{
-				TLSClientConfig: tlsConfig,
-			},
-		}
-	} else {
-		client = &http.Client{}
+	if verbosity > 0 {
+		opts = append(opts, catalog.WithTrace(os.Stderr, verbosity > 1))
 	}
-}
 
-func addAuthHeader(req *http.Request) {
-	// Only add token auth if cert/key not provided
-	if tlsCertPath == "" && tlsKeyPath == "" {
-		if token == "" {
-			fmt.Println("Error: either token or TLS certificate/key pair must be provided")
-			return
-		}
-		req.Header.Add("Authorization", fmt.Sprintf("Bearer %s", token))
+	limit, err := commandTimeout(ctx.Settings, cmd.Flags())
+	if err != nil {
+		return err
+	}
+	if limit > 0 {
+		timeoutCtx, cancel := context.WithTimeout(cmd.Context(), limit)
+		cancelTimeout = cancel
+		cmd.SetContext(timeoutCtx)
+	}
+
+	client, err = catalog.NewClient(baseUrl, opts...)
+	if err != nil {
+		return fmt.Errorf("%w: %w", errNoAuth, err)
 	}
+	return nil
 }
 
-func saveAuthConfig(filename string) {
-	// Ensure the directory exists
-	dir := filepath.Dir(filename)
-	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
-		fmt.Printf("error creating directory for auth config file: %v\n", err)
-		return
+func saveAuthConfig(filename string, name string) error {
+	config, err := loadConfig(filename)
+	if err != nil {
+		return err
 	}
 
-	authConfig := AuthConfig{
+	// Keep the settings of an existing context
+	ctx := Context{Name: name}
+	if existing := config.getContext(name); existing != nil {
+		ctx = *existing
+	}
+	ctx.AuthConfig = AuthConfig{
 		BaseUrl:     baseUrl,
 		Token:       token,
 		TLSCertPath: tlsCertPath,
 		TLSKeyPath:  tlsKeyPath,
 	}
+	config.setContext(ctx)
+	config.CurrentContext = name
 
-	file, err := os.Create(filename)
+	return saveConfig(filename, config)
+}
+
+// loadContext returns the named context, or the current context when name is
+// empty, after checking it holds valid authentication details.
+func loadContext(filename string, name string) (*Context, error) {
+	config, err := loadConfig(filename)
 	if err != nil {
-		fmt.Printf("error creating auth config file: %v\n", err)
-		return
+		return nil, err
 	}
-	defer file.Close()
 
-	encoder := json.NewEncoder(file)
-	if err := encoder.Encode(authConfig); err != nil {
-		fmt.Printf("error saving auth config: %v\n", err)
+	if name == "" {
+		name = config.CurrentContext
 	}
-}
-
-func loadAuthConfig(filename string) *AuthConfig {
-	file, err := os.Open(filename)
-	if err != nil {
-		if os.IsNotExist(err) {
-			// File does not exist, return nil
-			return nil
-		}
-		fmt.Printf("error opening auth config file: %v\n", err)
-		return nil
+	if name == "" {
+		return nil, fmt.Errorf("%w: no current context set, run 'backstagectl auth' or 'backstagectl config use-context'", errNoAuth)
 	}
-	defer file.Close()
 
-	var authConfig AuthConfig
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&authConfig); err != nil {
-		fmt.Printf("error loading auth config: %v\n", err)
-		return nil
+	ctx := config.getContext(name)
+	if ctx == nil {
+		return nil, fmt.Errorf("%w: no context exists with the name '%s'", errNoAuth, name)
 	}
 
-	if authConfig.BaseUrl == "" {
-		fmt.Println("Error: No baseUrl for the backstage instance provided")
-		return nil
+	if ctx.BaseUrl == "" {
+		return nil, fmt.Errorf("%w: no baseUrl for the backstage instance provided in context '%s'", errNoAuth, name)
 	}
 
 	// Validate loaded values
-	if authConfig.Token == "" && (authConfig.TLSCertPath == "" || authConfig.TLSKeyPath == "") {
-		fmt.Println("Error: No valid authentication details found in ~/.config/backstagectl/config.json")
-		return nil
+	if ctx.Token == "" && (ctx.TLSCertPath == "" || ctx.TLSKeyPath == "") {
+		return nil, fmt.Errorf("%w: no token or TLS certificate/key pair found in context '%s'", errNoAuth, name)
 	}
 
-	return &authConfig
+	return ctx, nil
 }
 
 var authCmd = &cobra.Command{
 	Use:   "auth",
-	Short: "Save authentication details to a file",
-	Run: func(cmd *cobra.Command, args []string) {
+	Short: "Save authentication details to a context",
+	Long: `Save authentication details to a named context in the config file.
+
+The context is the one given by --context, or the current context when the
+flag is omitted. The saved context becomes the current context.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
 		baseUrl, _ = cmd.Flags().GetString("baseUrl")
 		token, _ = cmd.Flags().GetString("token")
 		tlsCertPath, _ = cmd.Flags().GetString("tls-cert")
 		tlsKeyPath, _ = cmd.Flags().GetString("tls-key")
 
 		if baseUrl == "" {
-			fmt.Println("Error: No baseUrl for the backstage instance provided")
-			return
+			return fmt.Errorf("%w: no baseUrl for the backstage instance provided", errInvalidArgs)
 		}
 		// Validate input
 		if token == "" && (tlsCertPath == "" || tlsKeyPath == "") {
-			fmt.Println("Error: You must provide either a token or both TLS certificate and key paths")
-			return
+			return fmt.Errorf("%w: you must provide either a token or both TLS certificate and key paths", errInvalidArgs)
 		}
 
-		// Save the authentication details to ~/.config/backstagectl/config.json
-		saveAuthConfig(filepath.Join(getHomeDir(), ".config/backstagectl/config.json"))
-		fmt.Println("Authentication details saved to ~/.config/backstagectl/config.json")
+		// Save the authentication details as a context in ~/.config/backstagectl/config.json
+		path, err := configPath()
+		if err != nil {
+			return err
+		}
+		name := contextName
+		if name == "" {
+			config, err := loadConfig(path)
+			if err != nil {
+				return err
+			}
+			name = config.CurrentContext
+		}
+		if name == "" {
+			name = defaultContextName
+		}
+		if err := saveAuthConfig(path, name); err != nil {
+			return err
+		}
+		fmt.Printf("Authentication details saved to context '%s' in ~/.config/backstagectl/config.json\n", name)
+		return nil
 	},
 }
 
@@ -2,169 +2,427 @@ package cmd
 
 import (
 	"fmt"
-	"log"
+	"slices"
 	"strings"
 
 	"github.com/spf13/cobra"
+	"github.com/vcaldaralo/backstagectl/catalog"
 )
 
 var checkCmd = &cobra.Command{
 	Use:   "check",
 	Short: "Check issues in Backstage catalog",
+	// Unknown subcommands are usage errors, the command alone prints the help
+	Args: usageArgs(cobra.NoArgs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
 }
 
 var orphanCmd = &cobra.Command{
 	Use:   "orphan",
 	Short: "Orphan entities",
-	Run: func(cmd *cobra.Command, args []string) {
-		initAuth() // Initialize authentication
+	RunE: func(cmd *cobra.Command, args []string) error {
+		outputFormat, err := getOutputFormat(cmd)
+		if err != nil {
+			return err
+		}
+
+		if err := initAuth(cmd); err != nil {
+			return err
+		}
 
-		entities := fetchEntitiesByQuery("filter=metadata.annotations.backstage.io/orphan=true")
+		entities, err := client.QueryEntities(cmd.Context(), catalog.Query{
+			Filter: []string{"metadata.annotations.backstage.io/orphan=true"},
+		})
+		if err != nil {
+			return err
+		}
 
-		var data [][]string
+		t := table{header: []string{"NAMESPACE", "NAME", "URL"}}
 		for _, entity := range entities {
-			entityRef := getRefFromEntity(entity)
-			_, namespace, name := getKindNamespaceName(entityRef)
-			row := []string{
-				namespace,
-				name,
-				getUrlFromEntity(entity),
-			}
-			data = append(data, row)
+			ref := entity.Ref()
+			t.appendFinding(commandFinding(cmd, entity, "orphaned, its location was removed"), ref.Namespace, ref.Name, getUrlFromEntity(entity))
 		}
 
-		outputFormat, _ := cmd.Flags().GetString("output")
-		header := []string{"NAMESPACE", "NAME", "URL"}
-		formatOutput(header, data, outputFormat)
+		return formatOutput(t, outputFormat)
 	},
 }
 
 var missingAnnotationCmd = &cobra.Command{
 	Use:   "missingannotation [kind|entityRef] [annotation]",
 	Short: "Annotation that is missing for a group of entities",
-	Run: func(cmd *cobra.Command, args []string) {
-		initAuth()
+	RunE: func(cmd *cobra.Command, args []string) error {
+		outputFormat, err := getOutputFormat(cmd)
+		if err != nil {
+			return err
+		}
 
 		var annotation string
 
 		if len(args) < 2 {
-			log.Fatalf("Error: insufficient arguments. First argument must be 'kind' or 'entityRef', and second argument must be the annotation key.")
+			return fmt.Errorf("%w: insufficient arguments, first argument must be 'kind' or 'entityRef', and second argument must be the annotation key", errInvalidArgs)
 		} else if len(args) > 2 {
-			log.Fatalf("Error: too many arguments provided. Please specify exactly two arguments: 'kind' or 'entityRef' and the annotation key.")
+			return fmt.Errorf("%w: too many arguments provided, please specify exactly two arguments: 'kind' or 'entityRef' and the annotation key", errInvalidArgs)
 		}
 
-		filter := parseArgs(args[:len(args)-1])
+		if err := initAuth(cmd); err != nil {
+			return err
+		}
 
-		annotation = args[1]
+		filter, err := parseArgs(cmd.Context(), args[:len(args)-1])
+		if err != nil {
+			return err
+		}
 
-		params := fmt.Sprintf("fields=kind,metadata.namespace,metadata.name,metadata.annotation&%s", filter)
+		annotation = args[1]
 
-		entities := fetchEntitiesByQuery(params)
+		entities, err := client.QueryEntities(cmd.Context(), catalog.Query{
+			Filter: filter,
+			Fields: outputFields(outputFormat, "kind", "metadata.namespace", "metadata.name", "metadata.annotations"),
+		})
+		if err != nil {
+			return err
+		}
 
-		var data [][]string
+		t := table{header: []string{"NAMESPACE", "NAME", "MISSINGANNOTATION", "URL"}}
 		for _, entity := range entities {
-			_, ok := entity.Metadata.Annotations[annotation].(string)
+			_, ok := entity.Metadata.Annotations[annotation]
 			if !ok {
-				entityRef := getRefFromEntity(entity)
-				_, namespace, name := getKindNamespaceName(entityRef)
-				row := []string{
-					namespace,
-					name,
-					annotation,
-					getUrlFromEntity(entity),
-				}
-				data = append(data, row)
+				ref := entity.Ref()
+				t.appendFinding(commandFinding(cmd, entity, "missing annotation "+annotation), ref.Namespace, ref.Name, annotation, getUrlFromEntity(entity))
 			}
 		}
 
-		outputFormat, _ := cmd.Flags().GetString("output")
-		header := []string{"NAMESPACE", "NAME", "MISSINGANNOTATION", "URL"}
-		formatOutput(header, data, outputFormat)
+		return formatOutput(t, outputFormat)
 	},
 }
 
 var entityNotFoundCmd = &cobra.Command{
 	Use:   "notfound [kind|entityRef] [name]",
 	Short: "Relations that don't exist for an entity",
-	Run: func(cmd *cobra.Command, args []string) {
-		initAuth() // Initialize authentication
+	RunE: func(cmd *cobra.Command, args []string) error {
+		outputFormat, err := getOutputFormat(cmd)
+		if err != nil {
+			return err
+		}
 
 		if len(args) == 0 {
-			log.Fatalf("Error: no kind ([component|system|domain|group|user|location]) or entityRef ({kind}:{namespace}/{entity}) provided. Please specify one to check them")
+			return fmt.Errorf("%w: no kind or entityRef ({kind}:{namespace}/{entity}) provided, please specify one", errInvalidArgs)
 		} else if len(args) > 2 {
-			log.Fatalf("Error: too many arguments provided. Please specify either one or two arguments")
+			return fmt.Errorf("%w: too many arguments provided, please specify either one or two arguments", errInvalidArgs)
 		}
 
-		filter := parseArgs(args)
+		if err := initAuth(cmd); err != nil {
+			return err
+		}
 
-		params := fmt.Sprintf("fields=kind,metadata.namespace,metadata.name,relations&%s", filter)
-		entities := fetchEntitiesByQuery(params)
+		filter, err := parseArgs(cmd.Context(), args)
+		if err != nil {
+			return err
+		}
 
-		relationTarget := make(map[string][]string)
+		entities, err := client.QueryEntities(cmd.Context(), catalog.Query{
+			Filter: filter,
+			Fields: outputFields(outputFormat, "kind", "metadata.namespace", "metadata.name", "relations"),
+		})
+		if err != nil {
+			return err
+		}
+
+		relationTarget := make(map[string][]catalog.Entity)
 		for _, entity := range entities {
-			entityRef := getRefFromEntity(entity)
 			for _, rel := range entity.Relations {
 				if rel.Type == "dependsOn" || rel.Type == "partOf" || rel.Type == "ownedBy" {
-					relationTarget[rel.TargetRef] = append(relationTarget[rel.TargetRef], entityRef)
+					relationTarget[rel.TargetRef] = append(relationTarget[rel.TargetRef], entity)
 				}
 			}
 		}
-		if len(relationTarget) == 0 {
-			log.Fatalf("Relations 'dependsOn' or 'partOf' for these entities don't exist")
-		}
 
 		filterNotFoundEntities, _ := cmd.Flags().GetString("filter")
-		filterNotFoundEntities = addNamespaceDefault(filterNotFoundEntities)
+		if ref, err := catalog.ParseEntityRef(filterNotFoundEntities, catalog.EntityRef{}); err == nil {
+			filterNotFoundEntities = ref.String()
+		}
 
 		var verifyEntityRef []string
 		for notFoundEntity := range relationTarget {
-			if strings.Contains(notFoundEntity, filterNotFoundEntities) {
+			if strings.Contains(strings.ToLower(notFoundEntity), strings.ToLower(filterNotFoundEntities)) {
 				verifyEntityRef = append(verifyEntityRef, notFoundEntity)
 			}
 		}
 
-		payload := Payload{
-			EntityRefs: verifyEntityRef,
-			Fields:     []string{"kind", "metadata.name"},
-		}
-
 		if len(verifyEntityRef) > 0 {
-			entities = fetchEntitiesByRefs(payload)
+			entities, err = client.GetEntitiesByRefs(cmd.Context(), verifyEntityRef, []string{"kind", "metadata.name"})
+			if err != nil {
+				return err
+			}
 		}
 
-		var data [][]string
+		t := table{header: []string{"NAMESPACE", "NAME", "ENTITYNOTFOUND", "URL"}}
 		for i, entity := range entities {
 			if entity.Kind == "" {
-				entityNotFound := cleanNamespaceDefault(verifyEntityRef[i])
-				for _, usedin := range relationTarget[verifyEntityRef[i]] {
-					entityRef := usedin
-					_, namespace, name := getKindNamespaceName(entityRef)
-					row := []string{
-						namespace,
-						name,
-						entityNotFound,
-						getUrlFromRef(addNamespaceDefault(entityRef)),
-					}
-					data = append(data, row)
+				entityNotFound := verifyEntityRef[i]
+				if ref, err := catalog.ParseEntityRef(entityNotFound, catalog.EntityRef{}); err == nil {
+					entityNotFound = ref.Compact()
+				}
+				for _, usedIn := range relationTarget[verifyEntityRef[i]] {
+					ref := usedIn.Ref()
+					t.appendFinding(commandFinding(cmd, usedIn, "relation to "+entityNotFound+", which doesn't exist"), ref.Namespace, ref.Name, entityNotFound, getUrlFromRef(ref))
+				}
+			}
+		}
+
+		return formatOutput(t, outputFormat)
+	},
+}
+
+// ownedKinds are the kinds whose spec requires an owner.
+var ownedKinds = []string{"Component", "API", "System", "Resource", "Domain"}
+
+var ownerCmd = &cobra.Command{
+	Use:   "owner [kind|entityRef] [name]",
+	Short: "Entities with a missing, unresolvable or unsuitable owner",
+	Long: `Entities with a missing, unresolvable or unsuitable owner: no spec.owner,
+an owner that doesn't exist in the catalog, an owner that is a User rather
+than a Group, unless --allow-user-owners is set, or an owner Group without
+members. Components, APIs, Systems, Resources and Domains are checked unless
+a kind or entityRef is given.`,
+	Args: usageArgs(cobra.MaximumNArgs(2)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		allowUserOwners, _ := cmd.Flags().GetBool("allow-user-owners")
+		outputFormat, err := getOutputFormat(cmd)
+		if err != nil {
+			return err
+		}
+
+		if err := initAuth(cmd); err != nil {
+			return err
+		}
+
+		var filter []string
+		if len(args) > 0 {
+			if filter, err = parseArgs(cmd.Context(), args); err != nil {
+				return err
+			}
+		} else {
+			for _, kind := range ownedKinds {
+				filter = append(filter, fmt.Sprintf("kind=%s", kind))
+			}
+		}
+
+		entities, err := client.QueryEntities(cmd.Context(), catalog.Query{
+			Filter: filter,
+			Fields: outputFields(outputFormat, "kind", "metadata.namespace", "metadata.name", "spec.owner", "relations"),
+		})
+		if err != nil {
+			return err
+		}
+
+		// Owners are resolved in bulk, each of them once
+		owners := make(map[string]catalog.EntityRef)
+		var ownerRefs []string
+		for _, entity := range entities {
+			if owner, ok := ownerOf(entity); ok {
+				if _, seen := owners[owner.String()]; !seen {
+					ownerRefs = append(ownerRefs, owner.String())
+				}
+				owners[owner.String()] = owner
+			}
+		}
+
+		resolved := make(map[string]catalog.Entity)
+		if len(ownerRefs) > 0 {
+			found, err := client.GetEntitiesByRefs(cmd.Context(), ownerRefs, []string{"kind", "metadata.namespace", "metadata.name", "spec.members", "relations"})
+			if err != nil {
+				return err
+			}
+			for i, entity := range found {
+				resolved[ownerRefs[i]] = entity
+			}
+		}
+
+		t := table{header: []string{"NAMESPACE", "NAME", "OWNER", "PROBLEM", "URL"}}
+		for _, entity := range entities {
+			ref := entity.Ref()
+			owner, ok := ownerOf(entity)
+			if !ok {
+				t.appendFinding(commandFinding(cmd, entity, "no owner"), ref.Namespace, ref.Name, "", "no owner", getUrlFromEntity(entity))
+				continue
+			}
+
+			var problem string
+			ownerEntity := resolved[owner.String()]
+			switch {
+			case ownerEntity.Kind == "":
+				problem = "owner not found"
+			case strings.EqualFold(ownerEntity.Kind, "user"):
+				if !allowUserOwners {
+					problem = "owner is a user, not a group"
 				}
+			case strings.EqualFold(ownerEntity.Kind, "group"):
+				if !hasMembers(ownerEntity) {
+					problem = "owner group has no members"
+				}
+			}
+			if problem != "" {
+				t.appendFinding(commandFinding(cmd, entity, fmt.Sprintf("%s: %s", problem, owner.Compact())), ref.Namespace, ref.Name, owner.Compact(), problem, getUrlFromEntity(entity))
+			}
+		}
+
+		return formatOutput(t, outputFormat)
+	},
+}
+
+// ownerOf returns the owner of the entity, as resolved by the catalog into
+// an ownedBy relation, or else from spec.owner, which defaults to a group in
+// the namespace of the entity.
+func ownerOf(entity catalog.Entity) (catalog.EntityRef, bool) {
+	if owners := entity.RelationsOfType(catalog.RelationOwnedBy); len(owners) > 0 {
+		return owners[0], true
+	}
+	owner := entity.SpecString("owner")
+	if owner == "" {
+		return catalog.EntityRef{}, false
+	}
+	ref, err := catalog.ParseEntityRef(owner, catalog.EntityRef{Kind: "group", Namespace: entity.Metadata.Namespace})
+	if err != nil {
+		return catalog.EntityRef{Kind: "group", Namespace: entity.Metadata.Namespace, Name: owner}, true
+	}
+	return ref, true
+}
+
+// hasMembers reports whether the group has members, either users declaring
+// they are members of it or members listed in its spec.
+func hasMembers(group catalog.Entity) bool {
+	if len(group.RelationsOfType(catalog.RelationHasMember)) > 0 {
+		return true
+	}
+	members, _ := group.Spec["members"].([]interface{})
+	return len(members) > 0
+}
+
+var cyclesCmd = &cobra.Command{
+	Use:   "cycles [kind|entityRef] [name]",
+	Short: "Entities in a cycle of dependsOn or partOf relations",
+	Long: `Entities in a cycle of dependsOn or partOf relations, e.g. services
+depending on each other or systems part of each other. Each strongly
+connected component of the relation graph is reported once, on its first
+entity, with all of its entities and a cycle going through every one of
+them. All entities are checked unless a kind or entityRef is given.`,
+	Args: usageArgs(cobra.MaximumNArgs(2)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		relations, _ := cmd.Flags().GetStringSlice("relations")
+		outputFormat, err := getOutputFormat(cmd)
+		if err != nil {
+			return err
+		}
+
+		if err := initAuth(cmd); err != nil {
+			return err
+		}
+
+		filter, err := parseArgs(cmd.Context(), args)
+		if err != nil {
+			return err
+		}
+
+		entities, err := client.QueryEntities(cmd.Context(), catalog.Query{
+			Filter: filter,
+			Fields: outputFields(outputFormat, "kind", "metadata.namespace", "metadata.name", "relations"),
+		})
+		if err != nil {
+			return err
+		}
+
+		t := table{header: []string{"NAMESPACE", "NAME", "ENTITIES", "CYCLE", "URL"}}
+		for _, cycle := range findCycles(entities, relations) {
+			entity := cycle.entities[0]
+			entityRef := entity.Ref()
+			members := make([]string, len(cycle.entities))
+			for i, member := range cycle.entities {
+				members[i] = member.Ref().Compact()
 			}
+			message := fmt.Sprintf("in a cycle of %d entities: %s", len(members), cycle.path)
+			t.appendFinding(commandFinding(cmd, entity, message), entityRef.Namespace, entityRef.Name, strings.Join(members, ","), cycle.path, getUrlFromEntity(entity))
 		}
 
-		outputFormat, _ := cmd.Flags().GetString("output")
-		header := []string{"NAMESPACE", "NAME", "ENTITYNOTFOUND", "URL"}
-		formatOutput(header, data, outputFormat)
+		return formatOutput(t, outputFormat)
 	},
 }
 
+// entityCycle is a strongly connected component of the relation graph: its
+// entities, ordered by ref, and a cycle going through every one of them.
+type entityCycle struct {
+	entities []catalog.Entity
+	path     string
+}
+
+// findCycles returns the strongly connected components of the graph of the
+// given relations between the entities. Relations to other entities can't
+// close a cycle. Entities are indexed by the Key of their ref, as relations
+// may spell the names in another case.
+func findCycles(entities []catalog.Entity, relations []string) []entityCycle {
+	byRef := make(map[string]catalog.Entity)
+	var refs []string
+	for _, entity := range entities {
+		ref := entity.Ref().Key()
+		byRef[ref] = entity
+		refs = append(refs, ref)
+	}
+	adjacent := make(map[string][]string)
+	relationOf := make(map[[2]string]string)
+	for _, ref := range refs {
+		for _, relation := range byRef[ref].Relations {
+			target, err := catalog.ParseEntityRef(relation.TargetRef, catalog.EntityRef{})
+			if err != nil || !containsFold(relations, relation.Type) {
+				continue
+			}
+			if _, ok := byRef[target.Key()]; !ok {
+				continue
+			}
+			edge := [2]string{ref, target.Key()}
+			if _, ok := relationOf[edge]; !ok {
+				relationOf[edge] = relation.Type
+				adjacent[ref] = append(adjacent[ref], target.Key())
+			}
+		}
+	}
+
+	var cycles []entityCycle
+	for _, component := range stronglyConnected(refs, adjacent) {
+		slices.Sort(component)
+		path := coveringCycle(component, adjacent)
+		if path == nil {
+			continue
+		}
+
+		cycle := entityCycle{path: byRef[path[0]].Ref().Compact()}
+		for i := 1; i < len(path); i++ {
+			cycle.path += fmt.Sprintf(" -%s-> %s", relationOf[[2]string{path[i-1], path[i]}], byRef[path[i]].Ref().Compact())
+		}
+		for _, ref := range component {
+			cycle.entities = append(cycle.entities, byRef[ref])
+		}
+		cycles = append(cycles, cycle)
+	}
+	return cycles
+}
+
 func init() {
 	checkCmd.AddCommand(orphanCmd)
 	checkCmd.AddCommand(missingAnnotationCmd)
 	checkCmd.AddCommand(entityNotFoundCmd)
+	checkCmd.AddCommand(ownerCmd)
+	checkCmd.AddCommand(cyclesCmd)
 
-	orphanCmd.Flags().StringP("output", "o", "table", "Output format [table|json]")
-	missingAnnotationCmd.Flags().StringP("output", "o", "table", "Output format [table|json]")
-	entityNotFoundCmd.Flags().StringP("output", "o", "table", "Output format [table|json]")
+	addFindingsOutputFlag(orphanCmd)
+	addFindingsOutputFlag(missingAnnotationCmd)
+	addFindingsOutputFlag(entityNotFoundCmd)
 	entityNotFoundCmd.Flags().StringP("filter", "f", "", "Filter output on ENTITYNOTFOUND")
+	addFindingsOutputFlag(ownerCmd)
+	ownerCmd.Flags().Bool("allow-user-owners", false, "Accept users as owners")
+	addFindingsOutputFlag(cyclesCmd)
+	cyclesCmd.Flags().StringSlice("relations", []string{catalog.RelationDependsOn, catalog.RelationPartOf}, "Relation types forming the graph")
 
 	rootCmd.AddCommand(checkCmd)
 }
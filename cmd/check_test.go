@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/vcaldaralo/backstagectl/catalog"
+)
+
+func TestFindCycles(t *testing.T) {
+	dependsOn := func(ref string) catalog.Relation {
+		return catalog.Relation{Type: catalog.RelationDependsOn, TargetRef: ref}
+	}
+
+	tests := []struct {
+		name     string
+		entities []catalog.Entity
+		want     []string
+	}{
+		{
+			name: "no cycle",
+			entities: []catalog.Entity{
+				component("a", dependsOn("component:default/b")),
+				component("b"),
+			},
+		},
+		{
+			name: "two entities",
+			entities: []catalog.Entity{
+				component("a", dependsOn("component:default/b")),
+				component("b", dependsOn("component:default/a")),
+			},
+			want: []string{"component:a,component:b: component:a -dependsOn-> component:b -dependsOn-> component:a"},
+		},
+		{
+			name: "relation spelled in another case",
+			entities: []catalog.Entity{
+				component("Payments", dependsOn("component:default/ledger")),
+				component("ledger", dependsOn("component:default/payments")),
+			},
+			want: []string{"component:ledger,component:Payments: component:ledger -dependsOn-> component:Payments -dependsOn-> component:ledger"},
+		},
+		{
+			name: "self dependency",
+			entities: []catalog.Entity{
+				component("a", dependsOn("component:default/a")),
+			},
+			want: []string{"component:a: component:a -dependsOn-> component:a"},
+		},
+		{
+			name: "component made of two cycles",
+			entities: []catalog.Entity{
+				component("a", dependsOn("component:default/b")),
+				component("b", dependsOn("component:default/a"), dependsOn("component:default/c")),
+				component("c", dependsOn("component:default/b")),
+				component("d", dependsOn("component:default/a")),
+			},
+			want: []string{"component:a,component:b,component:c: component:a -dependsOn-> component:b -dependsOn-> component:c -dependsOn-> component:b -dependsOn-> component:a"},
+		},
+		{
+			name: "relation outside the selection",
+			entities: []catalog.Entity{
+				component("a", dependsOn("component:default/b")),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			for _, cycle := range findCycles(tt.entities, []string{catalog.RelationDependsOn}) {
+				var members []string
+				for _, entity := range cycle.entities {
+					members = append(members, entity.Ref().Compact())
+				}
+				got = append(got, strings.Join(members, ",")+": "+cycle.path)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("cycles = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,335 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+	"github.com/vcaldaralo/backstagectl/catalog"
+)
+
+const defaultContextName = "default"
+
+// Context is a named set of connection details for one Backstage instance.
+type Context struct {
+	Name string `json:"name"`
+	AuthConfig
+	Settings
+}
+
+// Settings tune how requests are sent to a Backstage instance. Unset values
+// fall back to the defaults of the matching flags.
+type Settings struct {
+	// Timeout limits the whole command, e.g. "5m".
+	Timeout string `json:"timeout,omitempty"`
+	// RequestTimeout limits every single HTTP request, e.g. "30s".
+	RequestTimeout string `json:"requestTimeout,omitempty"`
+	// MaxRetries is the number of retries of a failed request.
+	MaxRetries *int `json:"maxRetries,omitempty"`
+}
+
+// Config is the content of ~/.config/backstagectl/config.json. Like a
+// kubeconfig it holds several named contexts and remembers which one is used
+// when no --context flag is given.
+type Config struct {
+	CurrentContext string    `json:"current-context"`
+	Contexts       []Context `json:"contexts"`
+}
+
+// requestOptions returns the catalog client options for the request settings,
+// where flags given on the command line take precedence over the context.
+func requestOptions(settings Settings, flags *pflag.FlagSet) ([]catalog.Option, error) {
+	retry := catalog.DefaultRetryPolicy
+	retry.MaxRetries = maxRetries
+	if settings.MaxRetries != nil && !flags.Changed("max-retries") {
+		retry.MaxRetries = *settings.MaxRetries
+	}
+
+	perRequest := requestTimeout
+	if settings.RequestTimeout != "" && !flags.Changed("request-timeout") {
+		var err error
+		if perRequest, err = time.ParseDuration(settings.RequestTimeout); err != nil {
+			return nil, fmt.Errorf("invalid requestTimeout in config: %w", err)
+		}
+	}
+
+	return []catalog.Option{
+		catalog.WithRetry(retry),
+		catalog.WithRequestTimeout(perRequest),
+	}, nil
+}
+
+// commandTimeout returns the limit of the whole command, where the --timeout
+// flag takes precedence over the context. Zero means no limit.
+func commandTimeout(settings Settings, flags *pflag.FlagSet) (time.Duration, error) {
+	if settings.Timeout == "" || flags.Changed("timeout") {
+		return timeout, nil
+	}
+	d, err := time.ParseDuration(settings.Timeout)
+	if err != nil {
+		return 0, fmt.Errorf("invalid timeout in config: %w", err)
+	}
+	return d, nil
+}
+
+func configPath() (string, error) {
+	homeDir, err := getHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(homeDir, ".config/backstagectl/config.json"), nil
+}
+
+// getContext returns the context with the given name, or nil if it doesn't exist.
+func (c *Config) getContext(name string) *Context {
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == name {
+			return &c.Contexts[i]
+		}
+	}
+	return nil
+}
+
+// setContext adds the context or replaces the existing one with the same name.
+func (c *Config) setContext(ctx Context) {
+	if existing := c.getContext(ctx.Name); existing != nil {
+		*existing = ctx
+		return
+	}
+	c.Contexts = append(c.Contexts, ctx)
+}
+
+// deleteContext removes the named context and reports whether it existed.
+func (c *Config) deleteContext(name string) bool {
+	for i := range c.Contexts {
+		if c.Contexts[i].Name == name {
+			c.Contexts = append(c.Contexts[:i], c.Contexts[i+1:]...)
+			if c.CurrentContext == name {
+				c.CurrentContext = ""
+			}
+			return true
+		}
+	}
+	return false
+}
+
+// loadConfig reads the config file. A missing file yields an empty config.
+// Files written by older versions, which hold a single baseUrl/token pair at
+// the top level, are converted into a context named "default".
+func loadConfig(filename string) (*Config, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &Config{}, nil
+		}
+		return nil, fmt.Errorf("error opening config file: %w", err)
+	}
+
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("error loading config: %w", err)
+	}
+
+	if len(config.Contexts) == 0 {
+		var legacy AuthConfig
+		if err := json.Unmarshal(data, &legacy); err == nil && legacy.BaseUrl != "" {
+			config.Contexts = []Context{{Name: defaultContextName, AuthConfig: legacy}}
+			config.CurrentContext = defaultContextName
+		}
+	}
+
+	return &config, nil
+}
+
+func saveConfig(filename string, config *Config) error {
+	// Ensure the directory exists
+	dir := filepath.Dir(filename)
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("error creating directory for config file: %w", err)
+	}
+
+	// The file holds tokens, keep it private to the user
+	file, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("error creating config file: %w", err)
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(config); err != nil {
+		return fmt.Errorf("error saving config: %w", err)
+	}
+	return nil
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage contexts for multiple Backstage instances",
+	// Unknown subcommands are usage errors, the command alone prints the help
+	Args: usageArgs(cobra.NoArgs),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return cmd.Help()
+	},
+}
+
+var getContextsCmd = &cobra.Command{
+	Use:   "get-contexts",
+	Short: "List the contexts in the config file",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := configPath()
+		if err != nil {
+			return err
+		}
+		config, err := loadConfig(path)
+		if err != nil {
+			return err
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+		defer w.Flush()
+
+		fmt.Fprintln(w, "CURRENT\tNAME\tBASEURL\tAUTH")
+		for _, ctx := range config.Contexts {
+			current := ""
+			if ctx.Name == config.CurrentContext {
+				current = "*"
+			}
+			auth := "token"
+			if ctx.TLSCertPath != "" && ctx.TLSKeyPath != "" {
+				auth = "tls"
+			}
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", current, ctx.Name, ctx.BaseUrl, auth)
+		}
+		return nil
+	},
+}
+
+var currentContextCmd = &cobra.Command{
+	Use:   "current-context",
+	Short: "Display the current context",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := configPath()
+		if err != nil {
+			return err
+		}
+		config, err := loadConfig(path)
+		if err != nil {
+			return err
+		}
+		if config.CurrentContext == "" {
+			return fmt.Errorf("current-context is not set")
+		}
+		fmt.Println(config.CurrentContext)
+		return nil
+	},
+}
+
+var useContextCmd = &cobra.Command{
+	Use:   "use-context NAME",
+	Short: "Set the current context",
+	Args:  usageArgs(cobra.ExactArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := configPath()
+		if err != nil {
+			return err
+		}
+		config, err := loadConfig(path)
+		if err != nil {
+			return err
+		}
+		if config.getContext(args[0]) == nil {
+			return fmt.Errorf("%w: no context exists with the name '%s'", errInvalidArgs, args[0])
+		}
+
+		config.CurrentContext = args[0]
+		if err := saveConfig(path, config); err != nil {
+			return err
+		}
+		fmt.Printf("Switched to context '%s'\n", args[0])
+		return nil
+	},
+}
+
+var setContextCmd = &cobra.Command{
+	Use:   "set-context NAME",
+	Short: "Save request settings in a context",
+	Long: `Save request settings in an existing context.
+
+The values of the global --timeout, --request-timeout and --max-retries flags
+given on the command line are stored in the context and used by every
+following command run against it.`,
+	Example: `  backstagectl config set-context prod --timeout 5m --request-timeout 30s --max-retries 5`,
+	Args:    usageArgs(cobra.ExactArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := configPath()
+		if err != nil {
+			return err
+		}
+		config, err := loadConfig(path)
+		if err != nil {
+			return err
+		}
+		ctx := config.getContext(args[0])
+		if ctx == nil {
+			return fmt.Errorf("%w: no context exists with the name '%s'", errInvalidArgs, args[0])
+		}
+
+		flags := cmd.Flags()
+		if flags.Changed("timeout") {
+			ctx.Timeout = timeout.String()
+		}
+		if flags.Changed("request-timeout") {
+			ctx.RequestTimeout = requestTimeout.String()
+		}
+		if flags.Changed("max-retries") {
+			ctx.MaxRetries = &maxRetries
+		}
+
+		if err := saveConfig(path, config); err != nil {
+			return err
+		}
+		fmt.Printf("Context '%s' modified\n", args[0])
+		return nil
+	},
+}
+
+var deleteContextCmd = &cobra.Command{
+	Use:   "delete-context NAME",
+	Short: "Delete a context from the config file",
+	Args:  usageArgs(cobra.ExactArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, err := configPath()
+		if err != nil {
+			return err
+		}
+		config, err := loadConfig(path)
+		if err != nil {
+			return err
+		}
+		if !config.deleteContext(args[0]) {
+			return fmt.Errorf("%w: no context exists with the name '%s'", errInvalidArgs, args[0])
+		}
+
+		if err := saveConfig(path, config); err != nil {
+			return err
+		}
+		fmt.Printf("Deleted context '%s'\n", args[0])
+		return nil
+	},
+}
+
+func init() {
+	configCmd.AddCommand(getContextsCmd)
+	configCmd.AddCommand(currentContextCmd)
+	configCmd.AddCommand(useContextCmd)
+	configCmd.AddCommand(setContextCmd)
+	configCmd.AddCommand(deleteContextCmd)
+
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,214 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+	"github.com/vcaldaralo/backstagectl/catalog"
+)
+
+var describeCmd = &cobra.Command{
+	Use:   "describe <entityRef>",
+	Short: "Show details of a Backstage entity",
+	Long: `Show details of a Backstage entity: owner, system, domain, lifecycle,
+tags, links, labels, annotations, relations grouped by direction and the
+processing errors reported in its status.`,
+	Args: usageArgs(cobra.ExactArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ref, err := catalog.ParseEntityRef(args[0], catalog.EntityRef{})
+		if err != nil {
+			return err
+		}
+
+		if err := initAuth(cmd); err != nil {
+			return err
+		}
+
+		entity, err := client.GetEntityByRef(cmd.Context(), ref)
+		if err != nil {
+			return err
+		}
+
+		// The domain of an entity is the one of its system
+		domain := domainOf(entity)
+		if domain == "" {
+			for _, system := range entity.RelationsOfType(catalog.RelationPartOf) {
+				if !strings.EqualFold(system.Kind, "system") {
+					continue
+				}
+				found, err := client.GetEntitiesByRefs(cmd.Context(), []string{system.String()}, []string{"kind", "spec.domain", "relations"})
+				if err != nil {
+					return err
+				}
+				domain = domainOf(found[0])
+				break
+			}
+		}
+
+		return describeEntity(os.Stdout, entity, domain)
+	},
+}
+
+// domainOf returns the domain an entity is part of, for systems and
+// subdomains.
+func domainOf(entity catalog.Entity) string {
+	for _, ref := range entity.RelationsOfType(catalog.RelationPartOf) {
+		if strings.EqualFold(ref.Kind, "domain") {
+			return ref.String()
+		}
+	}
+	domain := entity.SpecString("domain")
+	if domain == "" || !strings.EqualFold(entity.Kind, "system") {
+		return ""
+	}
+	if ref, err := catalog.ParseEntityRef(domain, catalog.EntityRef{Kind: "domain", Namespace: entity.Metadata.Namespace}); err == nil {
+		return ref.String()
+	}
+	return domain
+}
+
+// describeEntity writes a human readable summary of the entity to out.
+func describeEntity(out io.Writer, entity catalog.Entity, domain string) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	field := func(name string, values ...string) {
+		if len(values) == 0 {
+			values = []string{"<none>"}
+		}
+		for i, value := range values {
+			if i == 0 {
+				fmt.Fprintf(w, "%s:\t%s\n", name, value)
+			} else {
+				fmt.Fprintf(w, "\t%s\n", value)
+			}
+		}
+	}
+	nonEmpty := func(value string) []string {
+		if value == "" {
+			return nil
+		}
+		return []string{value}
+	}
+
+	ref := entity.Ref()
+	field("Name", ref.Name)
+	field("Namespace", ref.Namespace)
+	field("Kind", entity.Kind)
+	field("Type", nonEmpty(entity.SpecString("type"))...)
+	field("Title", nonEmpty(entity.Metadata.Title)...)
+	field("Description", nonEmpty(entity.Metadata.Description)...)
+	field("Owner", refsOrSpec(entity, catalog.RelationOwnedBy, "", "owner")...)
+	field("System", refsOrSpec(entity, catalog.RelationPartOf, "system", "system")...)
+	field("Domain", nonEmpty(domain)...)
+	field("Lifecycle", nonEmpty(entity.SpecString("lifecycle"))...)
+	field("Tags", nonEmpty(strings.Join(entity.Metadata.Tags, ", "))...)
+	field("URL", getUrlFromEntity(entity))
+
+	field("Labels", keyValues(entity.Metadata.Labels)...)
+	field("Annotations", keyValues(entity.Metadata.Annotations)...)
+
+	var links []string
+	for _, link := range entity.Metadata.Links {
+		if link.Title != "" {
+			links = append(links, fmt.Sprintf("%s: %s", link.Title, link.URL))
+		} else {
+			links = append(links, link.URL)
+		}
+	}
+	field("Links", links...)
+
+	// Relations declared by the entity go out of it, their inverses come
+	// from the entities that declared them
+	outgoing, incoming := map[string][]string{}, map[string][]string{}
+	for _, relation := range entity.Relations {
+		if catalog.IsInverseRelation(relation.Type) {
+			incoming[relation.Type] = append(incoming[relation.Type], relation.TargetRef)
+		} else {
+			outgoing[relation.Type] = append(outgoing[relation.Type], relation.TargetRef)
+		}
+	}
+	if len(entity.Relations) == 0 {
+		field("Relations")
+	} else {
+		fmt.Fprintln(w, "Relations:")
+		describeRelations(w, "Outgoing", outgoing)
+		describeRelations(w, "Incoming", incoming)
+	}
+
+	var items []catalog.StatusItem
+	if entity.Status != nil {
+		items = entity.Status.Items
+	}
+	if len(items) == 0 {
+		field("Processing errors")
+	} else {
+		fmt.Fprintln(w, "Processing errors:")
+		fmt.Fprintln(w, "  LEVEL\tTYPE\tMESSAGE")
+		for _, item := range items {
+			fmt.Fprintf(w, "  %s\t%s\t%s\n", item.Level, item.Type, item.Message)
+		}
+	}
+
+	return w.Flush()
+}
+
+func describeRelations(w io.Writer, direction string, relations map[string][]string) {
+	if len(relations) == 0 {
+		fmt.Fprintf(w, "  %s: <none>\n", direction)
+		return
+	}
+	fmt.Fprintf(w, "  %s:\n", direction)
+
+	types := make([]string, 0, len(relations))
+	for relationType := range relations {
+		types = append(types, relationType)
+	}
+	sort.Strings(types)
+
+	for _, relationType := range types {
+		targets := relations[relationType]
+		sort.Strings(targets)
+		for i, target := range targets {
+			if i == 0 {
+				fmt.Fprintf(w, "    %s\t%s\n", relationType, target)
+			} else {
+				fmt.Fprintf(w, "    \t%s\n", target)
+			}
+		}
+	}
+}
+
+// refsOrSpec returns the targets of the entity's relations of the given type,
+// restricted to a kind when one is given, or the spec field when the catalog
+// didn't resolve it into relations.
+func refsOrSpec(entity catalog.Entity, relationType, kind, specField string) []string {
+	var refs []string
+	for _, ref := range entity.RelationsOfType(relationType) {
+		if kind == "" || strings.EqualFold(ref.Kind, kind) {
+			refs = append(refs, ref.String())
+		}
+	}
+	if len(refs) == 0 {
+		if value := entity.SpecString(specField); value != "" {
+			refs = append(refs, value)
+		}
+	}
+	return refs
+}
+
+func keyValues(m map[string]string) []string {
+	values := make([]string, 0, len(m))
+	for key, value := range m {
+		values = append(values, fmt.Sprintf("%s=%s", key, value))
+	}
+	sort.Strings(values)
+	return values
+}
+
+func init() {
+	rootCmd.AddCommand(describeCmd)
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/spf13/cobra"
+	"github.com/vcaldaralo/backstagectl/catalog"
+)
+
+// Exit codes of backstagectl, documented in the README. Scripts rely on them
+// to tell an empty result apart from a failure to talk to Backstage.
+const (
+	exitOK       = 0 // success, including commands that found nothing
+	exitError    = 1 // any error not covered below
+	exitUsage    = 2 // unknown commands, invalid arguments, flags or entity refs
+	exitAuth     = 3 // missing or rejected credentials
+	exitNotFound = 4 // the requested entity doesn't exist
+	exitNetwork  = 5 // Backstage could not be reached
+	exitServer   = 6 // Backstage answered with a server error
+	exitFindings = 7 // lint findings at or above the failing severity
+)
+
+var (
+	errInvalidArgs = errors.New("invalid arguments")
+	errNoAuth      = errors.New("no valid authentication details")
+	errFindings    = errors.New("policy violations")
+)
+
+func exitCode(err error) int {
+	switch {
+	case err == nil:
+		return exitOK
+	case errors.Is(err, errInvalidArgs), errors.Is(err, catalog.ErrInvalidRef):
+		return exitUsage
+	case errors.Is(err, errNoAuth), errors.Is(err, catalog.ErrUnauthorized):
+		return exitAuth
+	case errors.Is(err, catalog.ErrNotFound):
+		return exitNotFound
+	case errors.Is(err, catalog.ErrNetwork):
+		return exitNetwork
+	case errors.Is(err, catalog.ErrServer):
+		return exitServer
+	case errors.Is(err, errFindings):
+		return exitFindings
+	}
+	return exitError
+}
+
+// usageArgs marks errors of a positional argument validator as usage errors.
+func usageArgs(validate cobra.PositionalArgs) cobra.PositionalArgs {
+	return func(cmd *cobra.Command, args []string) error {
+		if err := validate(cmd, args); err != nil {
+			return fmt.Errorf("%w: %w", errInvalidArgs, err)
+		}
+		return nil
+	}
+}
+
+// printError writes err to w along with a hint on how to fix it and, in
+// verbose mode, the raw body of the failed response.
+func printError(w io.Writer, err error) {
+	fmt.Fprintf(w, "Error: %v\n", err)
+
+	var respErr *catalog.ResponseError
+	if !errors.As(err, &respErr) {
+		return
+	}
+
+	auth := "backstagectl auth"
+	if contextName != "" {
+		auth += " --context " + contextName
+	}
+	switch respErr.StatusCode {
+	case http.StatusUnauthorized:
+		fmt.Fprintf(w, "Hint: the token may be invalid or expired, run '%s' to save new credentials\n", auth)
+	case http.StatusForbidden:
+		fmt.Fprintf(w, "Hint: the credentials are not allowed to perform this request, check their permissions or run '%s' to use other ones\n", auth)
+	}
+
+	if verbosity > 0 && len(respErr.Body) > 0 {
+		fmt.Fprintf(w, "Response body:\n%s\n", respErr.Body)
+	}
+}
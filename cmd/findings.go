@@ -0,0 +1,422 @@
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vcaldaralo/backstagectl/catalog"
+)
+
+// severities are the severities of findings, from the least to the most
+// severe.
+var severities = []string{"info", "warning", "error"}
+
+// findingFormats are the output formats read by CI tools, supported by the
+// commands reporting findings.
+var findingFormats = []string{"sarif", "junit"}
+
+// findingsAnnotation marks the commands reporting findings.
+const findingsAnnotation = "findings"
+
+// sourceLocationAnnotations locate the source of an entity, in order of
+// preference.
+var sourceLocationAnnotations = []string{"backstage.io/source-location", "backstage.io/managed-by-location"}
+
+// repoFilePattern matches the URLs of files in GitHub and GitLab
+// repositories, capturing the host and path of the repository, e.g.
+// github.com/acme/web, and the path of the file in the repository.
+// Directories are under tree/ rather than blob/.
+var repoFilePattern = regexp.MustCompile(`^https?://([^/]+/.+?)/(?:-/)?blob/[^/]+/([^?#]*[^/?#])`)
+
+// checkFinding is a problem found on an entity by a check or a rule.
+type checkFinding struct {
+	entity catalog.Entity
+	rule   string
+	// description describes the rule, when it has one.
+	description string
+	severity    string
+	message     string
+}
+
+// ruleHeader is what the rules of policy and rules files have in common:
+// their id, description and severity, and the entities they apply to.
+type ruleHeader struct {
+	ID          string         `yaml:"id"`
+	Description string         `yaml:"description"`
+	Severity    string         `yaml:"severity"`
+	Selector    policySelector `yaml:"selector"`
+}
+
+// policySelector selects entities by kind, spec.type, spec.lifecycle and
+// namespace. Empty lists match every entity, values are compared case
+// insensitively.
+type policySelector struct {
+	Kind      []string `yaml:"kind"`
+	Type      []string `yaml:"type"`
+	Lifecycle []string `yaml:"lifecycle"`
+	Namespace []string `yaml:"namespace"`
+}
+
+// validate defaults the id of the rule at index i of its file and its
+// severity, and checks the severity.
+func (h *ruleHeader) validate(i int) error {
+	if h.ID == "" {
+		h.ID = fmt.Sprintf("rule-%d", i+1)
+	}
+	if h.Severity == "" {
+		h.Severity = "error"
+	}
+	h.Severity = strings.ToLower(h.Severity)
+	if !slices.Contains(severities, h.Severity) {
+		return fmt.Errorf("%w: rule %s: unknown severity '%s', supported severities are: %s", errInvalidArgs, h.ID, h.Severity, strings.Join(severities, ", "))
+	}
+	return nil
+}
+
+func (h ruleHeader) header() ruleHeader {
+	return h
+}
+
+// finding returns a finding of the rule on the entity.
+func (h *ruleHeader) finding(entity catalog.Entity, message string) checkFinding {
+	return checkFinding{entity: entity, rule: h.ID, description: h.Description, severity: h.Severity, message: message}
+}
+
+// kindsFilter returns the filter conditions on the kinds selected by the
+// rules, none when one of them applies to every kind.
+func kindsFilter[R interface{ header() ruleHeader }](rules []R) []string {
+	var kinds []string
+	for _, rule := range rules {
+		selector := rule.header().Selector
+		if len(selector.Kind) == 0 {
+			return nil
+		}
+		for _, kind := range selector.Kind {
+			if !containsFold(kinds, kind) {
+				kinds = append(kinds, kind)
+			}
+		}
+	}
+
+	filter := make([]string, len(kinds))
+	for i, kind := range kinds {
+		filter[i] = fmt.Sprintf("kind=%s", kind)
+	}
+	return filter
+}
+
+func (s policySelector) matches(entity catalog.Entity) bool {
+	matchesAny := func(values []string, value string) bool {
+		return len(values) == 0 || containsFold(values, value)
+	}
+	return matchesAny(s.Kind, entity.Kind) &&
+		matchesAny(s.Type, entity.SpecString("type")) &&
+		matchesAny(s.Lifecycle, entity.SpecString("lifecycle")) &&
+		matchesAny(s.Namespace, entity.Ref().Namespace)
+}
+
+// commandFinding returns a finding of a check command, the command being the
+// rule.
+func commandFinding(cmd *cobra.Command, entity catalog.Entity, message string) checkFinding {
+	return checkFinding{entity: entity, rule: cmd.Name(), description: cmd.Short, severity: "error", message: message}
+}
+
+// addFindingsOutputFlag adds the output flag to a command reporting
+// findings, which supports the finding formats as well.
+func addFindingsOutputFlag(cmd *cobra.Command) {
+	if cmd.Annotations == nil {
+		cmd.Annotations = make(map[string]string)
+	}
+	cmd.Annotations[findingsAnnotation] = "true"
+	addOutputFlag(cmd)
+}
+
+// supportedFormats returns the output formats supported by the command,
+// besides the template formats.
+func supportedFormats(cmd *cobra.Command) []string {
+	if cmd.Annotations[findingsAnnotation] != "" {
+		return append(slices.Clone(outputFormats), findingFormats...)
+	}
+	return outputFormats
+}
+
+// parseFailOn validates the value of --fail-on: a severity, or none.
+func parseFailOn(failOn string) (string, error) {
+	failOn = strings.ToLower(failOn)
+	if failOn != "none" && !slices.Contains(severities, failOn) {
+		return "", fmt.Errorf("%w: unknown severity '%s' for --fail-on, supported values are: %s, none", errInvalidArgs, failOn, strings.Join(severities, ", "))
+	}
+	return failOn, nil
+}
+
+// printFindings prints the findings and returns errFindings when some of them
+// are at least as severe as failOn.
+func printFindings(findings []checkFinding, outputFormat, failOn string) error {
+	t := table{header: []string{"NAMESPACE", "NAME", "RULE", "SEVERITY", "MESSAGE", "URL"}}
+	failing := 0
+	for _, finding := range findings {
+		ref := finding.entity.Ref()
+		t.appendFinding(finding, ref.Namespace, ref.Name, finding.rule, finding.severity, finding.message, getUrlFromEntity(finding.entity))
+		if failOn != "none" && slices.Index(severities, finding.severity) >= slices.Index(severities, failOn) {
+			failing++
+		}
+	}
+
+	if err := formatOutput(t, outputFormat); err != nil {
+		return err
+	}
+	if failing > 0 {
+		return fmt.Errorf("%w: %d findings with severity %s or higher", errFindings, failing, failOn)
+	}
+	return nil
+}
+
+// sourceLocation returns the URL of the source of the entity, from its
+// annotations, along with its path in repository, the host and path of the
+// scanned repository returned by scannedRepository. The first location
+// pointing at a file of that repository is preferred, e.g. the
+// catalog-info.yaml of managed-by-location over the directory of
+// source-location. Otherwise the first location pointing at a file of any
+// repository, or else the first location, is returned with no path, or ""
+// when the entity has none.
+func sourceLocation(entity catalog.Entity, repository string) (location, path string) {
+	var file string
+	for _, annotation := range sourceLocationAnnotations {
+		target := entity.Metadata.Annotations[annotation]
+		if target == "" {
+			continue
+		}
+		// Locations are prefixed with their type, e.g. url:https://...
+		if _, after, found := strings.Cut(target, ":"); found && !strings.HasPrefix(after, "//") {
+			target = after
+		}
+		if match := repoFilePattern.FindStringSubmatch(target); match != nil {
+			if repository != "" && strings.EqualFold(match[1], repository) {
+				return target, match[2]
+			}
+			if file == "" {
+				file = target
+			}
+		}
+		if location == "" {
+			location = target
+		}
+	}
+	if file != "" {
+		return file, ""
+	}
+	return location, ""
+}
+
+// scannedRepository returns the host and path of the repository the command
+// runs in, e.g. github.com/acme/web, from the variables set by GitHub Actions
+// and GitLab CI, or "" elsewhere.
+func scannedRepository() string {
+	repositories := []struct{ path, server, defaultHost string }{
+		{os.Getenv("GITHUB_REPOSITORY"), os.Getenv("GITHUB_SERVER_URL"), "github.com"},
+		{os.Getenv("CI_PROJECT_PATH"), os.Getenv("CI_SERVER_URL"), "gitlab.com"},
+	}
+	for _, repository := range repositories {
+		if repository.path == "" {
+			continue
+		}
+		host := repository.defaultHost
+		if server, err := url.Parse(repository.server); err == nil && server.Host != "" {
+			host = server.Host
+		}
+		return host + "/" + repository.path
+	}
+	return ""
+}
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool struct {
+		Driver sarifDriver `json:"driver"`
+	} `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string        `json:"id"`
+	ShortDescription     *sarifMessage `json:"shortDescription,omitempty"`
+	DefaultConfiguration struct {
+		Level string `json:"level"`
+	} `json:"defaultConfiguration"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	RuleIndex int             `json:"ruleIndex"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation *sarifPhysicalLocation `json:"physicalLocation,omitempty"`
+	LogicalLocations []sarifLogicalLocation `json:"logicalLocations"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation struct {
+		URI       string `json:"uri"`
+		URIBaseID string `json:"uriBaseId,omitempty"`
+	} `json:"artifactLocation"`
+}
+
+type sarifLogicalLocation struct {
+	Name               string `json:"name"`
+	FullyQualifiedName string `json:"fullyQualifiedName"`
+	Kind               string `json:"kind"`
+}
+
+// sarifLevel maps severities to SARIF levels.
+var sarifLevel = map[string]string{"info": "note", "warning": "warning", "error": "error"}
+
+// printSARIF writes the findings as a SARIF 2.1.0 log. Files of the scanned
+// repository are located relative to its root, so code scanning can show the
+// findings in them, other sources by their URL.
+func printSARIF(w io.Writer, findings []checkFinding) error {
+	repository := scannedRepository()
+	run := sarifRun{Results: []sarifResult{}}
+	run.Tool.Driver = sarifDriver{Name: "backstagectl", InformationURI: "https://github.com/vcaldaralo/backstagectl", Rules: []sarifRule{}}
+
+	ruleIndex := make(map[string]int)
+	for _, finding := range findings {
+		index, ok := ruleIndex[finding.rule]
+		if !ok {
+			rule := sarifRule{ID: finding.rule}
+			if finding.description != "" {
+				rule.ShortDescription = &sarifMessage{Text: finding.description}
+			}
+			rule.DefaultConfiguration.Level = sarifLevel[finding.severity]
+			index = len(run.Tool.Driver.Rules)
+			ruleIndex[finding.rule] = index
+			run.Tool.Driver.Rules = append(run.Tool.Driver.Rules, rule)
+		}
+
+		ref := finding.entity.Ref()
+		location := sarifLocation{LogicalLocations: []sarifLogicalLocation{{Name: ref.Name, FullyQualifiedName: ref.String(), Kind: "object"}}}
+		if source, path := sourceLocation(finding.entity, repository); path != "" {
+			location.PhysicalLocation = &sarifPhysicalLocation{}
+			location.PhysicalLocation.ArtifactLocation.URI = path
+			location.PhysicalLocation.ArtifactLocation.URIBaseID = "%SRCROOT%"
+		} else if source != "" {
+			location.PhysicalLocation = &sarifPhysicalLocation{}
+			location.PhysicalLocation.ArtifactLocation.URI = source
+		}
+
+		run.Results = append(run.Results, sarifResult{
+			RuleID:    finding.rule,
+			RuleIndex: index,
+			Level:     sarifLevel[finding.severity],
+			Message:   sarifMessage{Text: fmt.Sprintf("%s: %s", ref.Compact(), finding.message)},
+			Locations: []sarifLocation{location},
+		})
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(sarifLog{Schema: "https://json.schemastore.org/sarif-2.1.0.json", Version: "2.1.0", Runs: []sarifRun{run}}); err != nil {
+		return fmt.Errorf("error marshalling to SARIF: %w", err)
+	}
+	return nil
+}
+
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Name     string           `xml:"name,attr"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name     string          `xml:"name,attr"`
+	Tests    int             `xml:"tests,attr"`
+	Failures int             `xml:"failures,attr"`
+	Cases    []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string       `xml:"name,attr"`
+	Classname string       `xml:"classname,attr"`
+	File      string       `xml:"file,attr,omitempty"`
+	Failure   junitFailure `xml:"failure"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",cdata"`
+}
+
+// printJUnit writes the findings as a JUnit XML report, with a test suite per
+// rule and a failed test case per finding. Files are located as in SARIF.
+func printJUnit(w io.Writer, findings []checkFinding) error {
+	report := junitTestSuites{Name: "backstagectl", Tests: len(findings), Failures: len(findings)}
+	repository := scannedRepository()
+
+	suites := make(map[string]int)
+	for _, finding := range findings {
+		index, ok := suites[finding.rule]
+		if !ok {
+			index = len(report.Suites)
+			suites[finding.rule] = index
+			report.Suites = append(report.Suites, junitTestSuite{Name: finding.rule})
+		}
+
+		ref := finding.entity.Ref()
+		source, path := sourceLocation(finding.entity, repository)
+		file := path
+		if file == "" {
+			file = source
+		}
+		details := []string{finding.message, "entity: " + ref.String(), "url: " + getUrlFromEntity(finding.entity)}
+		if source != "" {
+			details = append(details, "source: "+source)
+		}
+
+		suite := &report.Suites[index]
+		suite.Tests++
+		suite.Failures++
+		suite.Cases = append(suite.Cases, junitTestCase{
+			Name:      ref.String(),
+			Classname: finding.rule,
+			File:      file,
+			Failure:   junitFailure{Message: finding.message, Type: finding.severity, Text: strings.Join(details, "\n")},
+		})
+	}
+
+	data, err := xml.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling to JUnit XML: %w", err)
+	}
+	fmt.Fprintln(w, xml.Header+string(data))
+	return nil
+}
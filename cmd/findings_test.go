@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"testing"
+
+	"github.com/vcaldaralo/backstagectl/catalog"
+)
+
+func TestSourceLocation(t *testing.T) {
+	tests := []struct {
+		name         string
+		annotations  map[string]string
+		repository   string
+		wantLocation string
+		wantPath     string
+	}{
+		{
+			name: "directory source, file managed-by",
+			annotations: map[string]string{
+				"backstage.io/source-location":     "url:https://github.com/acme/web/tree/main/",
+				"backstage.io/managed-by-location": "url:https://github.com/acme/web/blob/main/catalog-info.yaml",
+			},
+			repository:   "github.com/acme/web",
+			wantLocation: "https://github.com/acme/web/blob/main/catalog-info.yaml",
+			wantPath:     "catalog-info.yaml",
+		},
+		{
+			name: "file source",
+			annotations: map[string]string{
+				"backstage.io/source-location":     "url:https://gitlab.com/acme/web/-/blob/main/services/web/catalog-info.yaml",
+				"backstage.io/managed-by-location": "url:https://github.com/acme/web/blob/main/catalog-info.yaml",
+			},
+			repository:   "gitlab.com/acme/web",
+			wantLocation: "https://gitlab.com/acme/web/-/blob/main/services/web/catalog-info.yaml",
+			wantPath:     "services/web/catalog-info.yaml",
+		},
+		{
+			name: "file of the scanned repository preferred",
+			annotations: map[string]string{
+				"backstage.io/source-location":     "url:https://gitlab.com/acme/web/-/blob/main/services/web/catalog-info.yaml",
+				"backstage.io/managed-by-location": "url:https://github.com/acme/web/blob/main/catalog-info.yaml",
+			},
+			repository:   "github.com/Acme/Web",
+			wantLocation: "https://github.com/acme/web/blob/main/catalog-info.yaml",
+			wantPath:     "catalog-info.yaml",
+		},
+		{
+			name:         "GitLab subgroup",
+			annotations:  map[string]string{"backstage.io/managed-by-location": "url:https://gitlab.com/acme/payments/api/-/blob/main/catalog-info.yaml"},
+			repository:   "gitlab.com/acme/payments/api",
+			wantLocation: "https://gitlab.com/acme/payments/api/-/blob/main/catalog-info.yaml",
+			wantPath:     "catalog-info.yaml",
+		},
+		{
+			name: "file of another repository",
+			annotations: map[string]string{
+				"backstage.io/source-location":     "url:https://github.com/acme/pay/tree/main/",
+				"backstage.io/managed-by-location": "url:https://github.com/acme/pay/blob/main/catalog-info.yaml",
+			},
+			repository:   "github.com/acme/catalog",
+			wantLocation: "https://github.com/acme/pay/blob/main/catalog-info.yaml",
+		},
+		{
+			name:         "no scanned repository",
+			annotations:  map[string]string{"backstage.io/managed-by-location": "url:https://github.com/acme/web/blob/main/catalog-info.yaml"},
+			wantLocation: "https://github.com/acme/web/blob/main/catalog-info.yaml",
+		},
+		{
+			name:         "directory only",
+			annotations:  map[string]string{"backstage.io/source-location": "url:https://github.com/acme/web/tree/main/src/"},
+			repository:   "github.com/acme/web",
+			wantLocation: "https://github.com/acme/web/tree/main/src/",
+		},
+		{
+			name:         "other host",
+			annotations:  map[string]string{"backstage.io/managed-by-location": "file:/catalog/web.yaml"},
+			repository:   "github.com/acme/web",
+			wantLocation: "/catalog/web.yaml",
+		},
+		{
+			name: "none",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entity := catalog.Entity{Metadata: catalog.Metadata{Annotations: tt.annotations}}
+			location, path := sourceLocation(entity, tt.repository)
+			if location != tt.wantLocation || path != tt.wantPath {
+				t.Errorf("sourceLocation() = %q, %q, want %q, %q", location, path, tt.wantLocation, tt.wantPath)
+			}
+		})
+	}
+}
+
+func TestScannedRepository(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want string
+	}{
+		{"none", nil, ""},
+		{"GitHub", map[string]string{"GITHUB_REPOSITORY": "acme/web"}, "github.com/acme/web"},
+		{"GitHub Enterprise", map[string]string{"GITHUB_REPOSITORY": "acme/web", "GITHUB_SERVER_URL": "https://github.acme.com"}, "github.acme.com/acme/web"},
+		{"GitLab", map[string]string{"CI_PROJECT_PATH": "acme/payments/api", "CI_SERVER_URL": "https://gitlab.acme.com"}, "gitlab.acme.com/acme/payments/api"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, name := range []string{"GITHUB_REPOSITORY", "GITHUB_SERVER_URL", "CI_PROJECT_PATH", "CI_SERVER_URL"} {
+				t.Setenv(name, tt.env[name])
+			}
+			if got := scannedRepository(); got != tt.want {
+				t.Errorf("scannedRepository() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -1,68 +1,313 @@
 package cmd
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
-	"log"
+	"iter"
+	"strings"
 
 	"github.com/spf13/cobra"
-	"gopkg.in/yaml.v3"
+	"github.com/vcaldaralo/backstagectl/catalog"
 )
 
+// rowFields are the entity fields read by the rows get prints.
+var rowFields = []string{"kind", "metadata.namespace", "metadata.name"}
+
+// printsEntities reports whether the output format prints the entities
+// themselves rather than the rows, so every field is fetched by default.
+func printsEntities(outputFormat string) bool {
+	return outputFormat == "json" || outputFormat == "jsonl" || outputFormat == "yaml"
+}
+
+// computedFields are derived from an entity by backstagectl, they are not
+// part of the entity stored in the catalog.
+type computedFields struct {
+	WebURL    string `json:"webUrl" yaml:"webUrl"`
+	EntityRef string `json:"entityRef" yaml:"entityRef"`
+}
+
+// entityWithComputed is the JSON document printed for an entity along with
+// its computed fields, which a single JSON document can't hold side by side.
+type entityWithComputed struct {
+	Entity   catalog.Entity `json:"entity"`
+	Computed computedFields `json:"computed"`
+}
+
+// printEntity prints a single entity as it is stored in the catalog, in json
+// or yaml. When showComputed is set, the computed fields follow the entity in
+// a separate YAML document, or the JSON document holds both the entity and
+// its computed fields.
+func printEntity(entity catalog.Entity, outputFormat string, showComputed bool) error {
+	computed := map[string]computedFields{
+		"computed": {WebURL: getUrlFromEntity(entity), EntityRef: entity.Ref().Compact()},
+	}
+
+	if outputFormat == "json" {
+		var document interface{} = entity
+		if showComputed {
+			document = entityWithComputed{Entity: entity, Computed: computed["computed"]}
+		}
+		jsonData, err := json.MarshalIndent(document, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshalling to JSON: %w", err)
+		}
+		fmt.Println(string(jsonData))
+		return nil
+	}
+
+	if err := printYaml(entity); err != nil {
+		return err
+	}
+	if showComputed {
+		fmt.Println("---")
+		fmt.Println("# Computed by backstagectl, not part of the entity")
+		return printYaml(computed)
+	}
+	return nil
+}
+
 var getCmd = &cobra.Command{
 	Use:   "get [kind|entityRef] [name]",
 	Short: "Display one or many Backstage entities",
-	Run: func(cmd *cobra.Command, args []string) {
-		initAuth() // Initialize authentication
+	RunE: func(cmd *cobra.Command, args []string) error {
 		annotation, _ := cmd.Flags().GetString("annotation")
+		labelSelector, _ := cmd.Flags().GetString("selector")
+		fieldSelector, _ := cmd.Flags().GetString("field-selector")
+		search, _ := cmd.Flags().GetString("search")
+		searchFields, _ := cmd.Flags().GetStringSlice("search-fields")
+		sortBy, _ := cmd.Flags().GetStringArray("sort-by")
+		limit, _ := cmd.Flags().GetInt("limit")
+		fields, _ := cmd.Flags().GetStringSlice("fields")
+		showComputed, _ := cmd.Flags().GetBool("show-computed")
+		outputFormat, err := getOutputFormat(cmd)
+		if err != nil {
+			return err
+		}
+
+		labels, err := parseLabelSelector(labelSelector)
+		if err != nil {
+			return err
+		}
+		fieldRequirements, err := parseFieldSelector(fieldSelector)
+		if err != nil {
+			return err
+		}
+		sel := append(labels, fieldRequirements...)
+
+		order, err := parseSortBy(sortBy)
+		if err != nil {
+			return err
+		}
+		if len(searchFields) > 0 && search == "" {
+			return fmt.Errorf("%w: --search-fields requires --search", errInvalidArgs)
+		}
+		if limit < 0 {
+			return fmt.Errorf("%w: --limit must not be negative", errInvalidArgs)
+		}
+		if showComputed && cmd.Flags().Changed("output") && outputFormat != "json" && outputFormat != "yaml" {
+			return fmt.Errorf("%w: --show-computed requires the json or yaml output", errInvalidArgs)
+		}
 
 		if len(args) == 0 {
-			log.Fatalf("Error: no kind ([component|system|domain|group|user|location]) or entityRef ({kind}:{namespace}/{entity}) provided. Please specify one to check them")
+			return fmt.Errorf("%w: no kind or entityRef ({kind}:{namespace}/{entity}) provided, please specify one", errInvalidArgs)
 		}
 
-		filter := parseArgs(args)
+		if err := initAuth(cmd); err != nil {
+			return err
+		}
+
+		filter, err := parseArgs(cmd.Context(), args)
+		if err != nil {
+			return err
+		}
 
 		if annotation != "" {
-			if filter != "" {
-				filter += fmt.Sprintf(",metadata.annotations.%s", annotation)
-			} else {
-				filter = fmt.Sprintf("filter=metadata.annotations.%s", annotation)
+			filter = append(filter, fmt.Sprintf("metadata.annotations.%s", annotation))
+		}
+		filter = append(filter, sel.filter()...)
+
+		// Only the fields printed are fetched, unless the entities themselves are
+		projected := len(fields) > 0
+		if !printsEntities(outputFormat) {
+			fields = outputFields(outputFormat, append(fields, rowFields...)...)
+			projected = fields != nil
+		}
+		if projected {
+			fields = append(fields, sel.fields()...)
+		}
+
+		query := catalog.Query{
+			Filter:         filter,
+			Fields:         fields,
+			FullTextTerm:   search,
+			FullTextFields: searchFields,
+			Order:          order,
+			Limit:          limit,
+		}
+
+		t := table{
+			header:  []string{"NAMESPACE", "NAME", "URL"},
+			objects: true,
+			ordered: len(order) > 0,
+		}
+		row := func(entity catalog.Entity) []string {
+			ref := entity.Ref()
+			return []string{ref.Namespace, ref.Name, getUrlFromEntity(entity)}
+		}
+
+		// A single entity is shown in full, as stored in the catalog, rather
+		// than fetched with the fields of the rows only
+		printFull := func(entity catalog.Entity) error {
+			if projected && !cmd.Flags().Changed("fields") {
+				full, err := client.GetEntitiesByRefs(cmd.Context(), []string{entity.Ref().String()}, nil)
+				if err != nil {
+					return err
+				}
+				if full[0].Kind != "" {
+					entity = full[0]
+				}
 			}
+			if !cmd.Flags().Changed("output") {
+				outputFormat = "yaml"
+			}
+			return printEntity(entity, outputFormat, showComputed)
 		}
 
-		entities := fetchEntitiesByQuery(filter)
+		// Formats printing rows on their own start before the last page is
+		// read, with the catalog sorting the entities instead of the table
+		if streams(outputFormat) {
+			if len(query.Order) == 0 {
+				query.Order = []catalog.OrderField{{Field: "metadata.namespace"}, {Field: "metadata.name"}}
+			}
+			entities := selectEntities(cmd.Context(), query, sel)
+			if !cmd.Flags().Changed("output") {
+				single, all, err := singleEntity(entities)
+				if err != nil {
+					return err
+				}
+				if single != nil {
+					return printFull(*single)
+				}
+				entities = all
+			}
+			if showComputed {
+				return errShowComputed
+			}
+			// Tables keep the NAMESPACE column unless every entity the catalog
+			// may return is in the default namespace
+			if name, _ := splitOutputFormat(outputFormat); name == "table" || name == "wide" {
+				if t.keepNamespace, err = otherNamespaces(cmd.Context(), filter); err != nil {
+					return err
+				}
+			}
+			return streamOutput(t, entities, row, outputFormat)
+		}
 
-		if len(entities) == 1 {
-			entity := entities[0]
-			entities[0].Metadata.Annotations["backstage.io/web-url"] = getUrlFromEntity(entity)
-			entities[0].Metadata.Annotations["backstage.io/entity-ref"] = getRefFromEntity(entity)
-			marshaledYAML, err := yaml.Marshal(entities[0])
+		var entities []catalog.Entity
+		for entity, err := range selectEntities(cmd.Context(), query, sel) {
 			if err != nil {
-				fmt.Println("error marshalling YAML:", err)
+				return err
+			}
+			entities = append(entities, entity)
+		}
+
+		// Unless an output format is asked, a single entity is printed as an
+		// object rather than a list when it was named
+		namesEntity := len(args) > 1 || strings.Contains(args[0], ":")
+		if len(entities) == 1 && (!cmd.Flags().Changed("output") || (namesEntity && (outputFormat == "json" || outputFormat == "yaml"))) {
+			return printFull(entities[0])
+		}
+		if showComputed {
+			return errShowComputed
+		}
+
+		for _, entity := range entities {
+			t.append(entity, row(entity)...)
+		}
+		return formatOutput(t, outputFormat)
+	},
+}
+
+// selectEntities returns the entities of the query matching the selector.
+// The catalog applies the limit, unless the selector has requirements it
+// can't express: the limit then applies to the entities left once they are
+// checked, so there are no fewer than asked when the catalog has enough.
+func selectEntities(ctx context.Context, query catalog.Query, sel selector) iter.Seq2[catalog.Entity, error] {
+	if !sel.clientSide() {
+		return sel.filterEntities(client.Entities(ctx, query))
+	}
+	limit := query.Limit
+	query.Limit = 0
+	return limitEntities(sel.filterEntities(client.Entities(ctx, query)), limit)
+}
+
+// errShowComputed is returned when --show-computed is set but get prints
+// rows or a list rather than a single entity.
+var errShowComputed = fmt.Errorf("%w: --show-computed only applies when a single entity is printed, name one, e.g. component:payments", errInvalidArgs)
+
+// singleEntity reads the entities up to the second one. It returns the
+// entity when there is exactly one, otherwise an iterator over all of them.
+func singleEntity(entities iter.Seq2[catalog.Entity, error]) (*catalog.Entity, iter.Seq2[catalog.Entity, error], error) {
+	next, stop := iter.Pull2(entities)
+	var read []catalog.Entity
+	for len(read) < 2 {
+		entity, err, ok := next()
+		if err != nil {
+			stop()
+			return nil, nil, err
+		}
+		if !ok {
+			break
+		}
+		read = append(read, entity)
+	}
+	if len(read) == 1 {
+		stop()
+		return &read[0], nil, nil
+	}
+
+	return nil, func(yield func(catalog.Entity, error) bool) {
+		defer stop()
+		for _, entity := range read {
+			if !yield(entity, nil) {
 				return
 			}
-			fmt.Print(string(marshaledYAML))
-		} else {
-			var data [][]string
-			for _, entity := range entities {
-				entityRef := getRefFromEntity(entity)
-				_, namespace, name := getKindNamespaceName(entityRef)
-				newRow := []string{
-					namespace,
-					name,
-					getUrlFromEntity(entity),
-				}
-				data = append(data, newRow)
+		}
+		for {
+			entity, err, ok := next()
+			if !ok || !yield(entity, err) {
+				return
 			}
+		}
+	}, nil
+}
 
-			outputFormat, _ := cmd.Flags().GetString("output")
-			header := []string{"NAMESPACE", "NAME", "URL"}
-			formatOutput(header, data, outputFormat)
+// otherNamespaces reports whether entities matching the filter are in other
+// namespaces than the default one.
+func otherNamespaces(ctx context.Context, filter []string) (bool, error) {
+	namespaces, err := client.Facets(ctx, "metadata.namespace", filter)
+	if err != nil {
+		return false, err
+	}
+	for _, namespace := range namespaces {
+		if !strings.EqualFold(namespace.Value, catalog.DefaultNamespace) {
+			return true, nil
 		}
-	},
+	}
+	return false, nil
 }
 
 func init() {
 	getCmd.Flags().StringP("annotation", "a", "", "Filter entities by annotation key")
-	getCmd.Flags().StringP("output", "o", "table", "Output format [table|json]")
+	getCmd.Flags().StringP("selector", "l", "", "Label selector, e.g. tier=gold,env!=dev,region in (eu,us),!deprecated")
+	getCmd.Flags().String("field-selector", "", "Field selector on dotted paths, e.g. spec.lifecycle=production,spec.owner!=group:team-a")
+	getCmd.Flags().String("search", "", "Full-text search term")
+	getCmd.Flags().StringSlice("search-fields", nil, "Fields searched by --search, e.g. metadata.name,metadata.description (default all)")
+	getCmd.Flags().StringArray("sort-by", nil, "Sort by field in the catalog, e.g. metadata.name,desc (repeatable)")
+	getCmd.Flags().Int("limit", 0, "Maximum number of entities to fetch (default all)")
+	getCmd.Flags().StringSlice("fields", nil, "Entity fields to fetch, e.g. metadata.name,spec.owner (default those printed)")
+	getCmd.Flags().Bool("show-computed", false, "Show the web URL and entity ref of a single entity in a separate section, or an object wrapping both with -o json")
+	addOutputFlag(getCmd)
 	rootCmd.AddCommand(getCmd)
 }
@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"testing"
+
+	"github.com/vcaldaralo/backstagectl/catalog"
+)
+
+func TestSelectEntitiesLimit(t *testing.T) {
+	items := []catalog.Entity{
+		{Kind: "Component", Metadata: catalog.Metadata{Namespace: "default", Name: "payments", Labels: map[string]string{"env": "prod"}}},
+		{Kind: "Component", Metadata: catalog.Metadata{Namespace: "default", Name: "ledger", Labels: map[string]string{"env": "prod"}}},
+		{Kind: "Component", Metadata: catalog.Metadata{Namespace: "default", Name: "api", Labels: map[string]string{"env": "dev"}}},
+		{Kind: "Component", Metadata: catalog.Metadata{Namespace: "default", Name: "web", Labels: map[string]string{"env": "dev"}}},
+	}
+
+	var sentLimit string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sentLimit = r.URL.Query().Get("limit")
+		page := items
+		if limit, err := strconv.Atoi(sentLimit); err == nil && limit < len(page) {
+			page = page[:limit]
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"items": page, "totalItems": len(items)})
+	}))
+	defer server.Close()
+
+	var err error
+	if client, err = catalog.NewClient(server.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		selector  string
+		limit     int
+		sentLimit string
+		want      []string
+	}{
+		{selector: "", limit: 1, sentLimit: "1", want: []string{"payments"}},
+		{selector: "env!=prod", limit: 1, sentLimit: "", want: []string{"api"}},
+		{selector: "env notin (prod)", limit: 2, sentLimit: "", want: []string{"api", "web"}},
+		{selector: "!tier", limit: 3, sentLimit: "", want: []string{"payments", "ledger", "api"}},
+		{selector: "env!=prod", limit: 0, sentLimit: "", want: []string{"api", "web"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.selector, func(t *testing.T) {
+			sel, err := parseLabelSelector(tt.selector)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for entity, err := range selectEntities(context.Background(), catalog.Query{Limit: tt.limit}, sel) {
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, entity.Metadata.Name)
+			}
+
+			if sentLimit != tt.sentLimit {
+				t.Errorf("limit sent to the catalog = %q, want %q", sentLimit, tt.sentLimit)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("entities = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSingleEntity(t *testing.T) {
+	tests := []struct {
+		names  []string
+		single string
+	}{
+		{names: nil},
+		{names: []string{"payments"}, single: "payments"},
+		{names: []string{"payments", "ledger"}},
+		{names: []string{"payments", "ledger", "web"}},
+	}
+	for _, tt := range tests {
+		t.Run(fmt.Sprint(len(tt.names)), func(t *testing.T) {
+			entities := func(yield func(catalog.Entity, error) bool) {
+				for _, name := range tt.names {
+					if !yield(component(name), nil) {
+						return
+					}
+				}
+			}
+
+			single, all, err := singleEntity(entities)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.single != "" {
+				if single == nil || single.Metadata.Name != tt.single {
+					t.Errorf("single entity = %v, want %s", single, tt.single)
+				}
+				return
+			}
+			if single != nil {
+				t.Fatalf("single entity = %s, want none", single.Metadata.Name)
+			}
+			var got []string
+			for entity, err := range all {
+				if err != nil {
+					t.Fatal(err)
+				}
+				got = append(got, entity.Metadata.Name)
+			}
+			if !slices.Equal(got, tt.names) {
+				t.Errorf("entities = %v, want %v", got, tt.names)
+			}
+		})
+	}
+}
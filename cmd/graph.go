@@ -0,0 +1,303 @@
+package cmd
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vcaldaralo/backstagectl/catalog"
+)
+
+var graphFormats = []string{"dot", "mermaid", "graphml", "json"}
+
+// defaultGraphRelations are the relation types declared in entity specs.
+var defaultGraphRelations = []string{
+	catalog.RelationOwnedBy,
+	catalog.RelationPartOf,
+	catalog.RelationDependsOn,
+	catalog.RelationConsumesAPI,
+	catalog.RelationProvidesAPI,
+	catalog.RelationMemberOf,
+	catalog.RelationChildOf,
+}
+
+var graphCmd = &cobra.Command{
+	Use:   "graph <entityRef>",
+	Short: "Export the relation graph around an entity",
+	Long: `Export the relation graph around an entity, walking the given relations
+from it up to --depth relations away, as Graphviz DOT, Mermaid, GraphML or
+JSON.
+
+With --both, the inverse relations are followed too, reaching the entities
+that point at the root, e.g. those that depend on it. Their edges are drawn
+in the declared direction, from the dependent to the dependency.`,
+	Example: `  backstagectl graph component:payments --relations dependsOn,partOf --depth 3 | dot -Tsvg > payments.svg
+  backstagectl graph system:billing --both --format mermaid`,
+	Args: usageArgs(cobra.ExactArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		depth, _ := cmd.Flags().GetInt("depth")
+		relations, _ := cmd.Flags().GetStringSlice("relations")
+		both, _ := cmd.Flags().GetBool("both")
+		format, _ := cmd.Flags().GetString("format")
+
+		if !containsFold(graphFormats, format) {
+			return fmt.Errorf("%w: unknown graph format '%s', supported formats are: %s", errInvalidArgs, format, strings.Join(graphFormats, ", "))
+		}
+		if depth < 0 {
+			return fmt.Errorf("%w: --depth must not be negative", errInvalidArgs)
+		}
+		root, err := catalog.ParseEntityRef(args[0], catalog.EntityRef{})
+		if err != nil {
+			return err
+		}
+
+		if err := initAuth(cmd); err != nil {
+			return err
+		}
+
+		follow := relations
+		if both {
+			for _, relation := range relations {
+				if inverse := catalog.InverseRelation(relation); inverse != "" {
+					follow = append(follow, inverse)
+				}
+			}
+		}
+
+		g, err := walkRelations(cmd.Context(), root, follow, depth)
+		if err != nil {
+			return err
+		}
+
+		edges := declaredEdges(g.edges, relations)
+		switch strings.ToLower(format) {
+		case "dot":
+			return printDot(os.Stdout, g, edges)
+		case "mermaid":
+			return printMermaid(os.Stdout, g, edges)
+		case "graphml":
+			return printGraphML(os.Stdout, g, edges)
+		default:
+			return printGraphJSON(os.Stdout, g, edges)
+		}
+	},
+}
+
+// declaredEdges returns the edges in the direction of the requested relation
+// types: an inverse relation found on an entity, e.g. dependencyOf, is turned
+// into the relation declared by its target, e.g. dependsOn. Edges found from
+// both ends are kept once.
+func declaredEdges(edges []graphEdge, relations []string) []graphEdge {
+	seen := map[string]bool{}
+	var declared []graphEdge
+	for _, edge := range edges {
+		if !containsFold(relations, edge.relation) {
+			if inverse := catalog.InverseRelation(edge.relation); inverse != "" && containsFold(relations, inverse) {
+				edge = graphEdge{from: edge.to, to: edge.from, relation: inverse}
+			}
+		}
+		key := edge.from.Key() + " " + strings.ToLower(edge.relation) + " " + edge.to.Key()
+		if !seen[key] {
+			seen[key] = true
+			declared = append(declared, edge)
+		}
+	}
+	return declared
+}
+
+func printDot(w io.Writer, g *relationGraph, edges []graphEdge) error {
+	fmt.Fprintf(w, "digraph %s {\n", dotQuote(g.nodes[0].ref.String()))
+	fmt.Fprintln(w, "  rankdir=LR;")
+	fmt.Fprintln(w, "  node [shape=box];")
+	for i, node := range g.nodes {
+		attrs := []string{"label=" + dotQuote(nodeLabel(node))}
+		if i == 0 {
+			attrs = append(attrs, "style=bold")
+		} else if node.missing() {
+			attrs = append(attrs, "style=dashed")
+		}
+		fmt.Fprintf(w, "  %s [%s];\n", dotQuote(node.ref.String()), strings.Join(attrs, ", "))
+	}
+	for _, edge := range edges {
+		fmt.Fprintf(w, "  %s -> %s [label=%s];\n", dotQuote(edge.from.String()), dotQuote(edge.to.String()), dotQuote(edge.relation))
+	}
+	fmt.Fprintln(w, "}")
+	return nil
+}
+
+func printMermaid(w io.Writer, g *relationGraph, edges []graphEdge) error {
+	ids := map[string]string{}
+	fmt.Fprintln(w, "graph LR")
+	for i, node := range g.nodes {
+		id := fmt.Sprintf("n%d", i)
+		ids[node.ref.Key()] = id
+		label := strings.ReplaceAll(nodeLabel(node), `"`, "#quot;")
+		label = strings.ReplaceAll(label, "\n", "<br/>")
+		fmt.Fprintf(w, "  %s[\"%s\"]\n", id, label)
+	}
+	for _, edge := range edges {
+		fmt.Fprintf(w, "  %s -->|%s| %s\n", ids[edge.from.Key()], edge.relation, ids[edge.to.Key()])
+	}
+	if len(g.nodes) > 0 {
+		fmt.Fprintln(w, "  style n0 stroke-width:3px")
+	}
+	for i, node := range g.nodes {
+		if node.missing() {
+			fmt.Fprintf(w, "  style n%d stroke-dasharray:5 5\n", i)
+		}
+	}
+	return nil
+}
+
+type graphMLDocument struct {
+	XMLName xml.Name     `xml:"graphml"`
+	Xmlns   string       `xml:"xmlns,attr"`
+	Keys    []graphMLKey `xml:"key"`
+	Graph   struct {
+		ID          string        `xml:"id,attr"`
+		EdgeDefault string        `xml:"edgedefault,attr"`
+		Nodes       []graphMLNode `xml:"node"`
+		Edges       []graphMLEdge `xml:"edge"`
+	} `xml:"graph"`
+}
+
+type graphMLKey struct {
+	ID       string `xml:"id,attr"`
+	For      string `xml:"for,attr"`
+	AttrName string `xml:"attr.name,attr"`
+	AttrType string `xml:"attr.type,attr"`
+}
+
+type graphMLData struct {
+	Key   string `xml:"key,attr"`
+	Value string `xml:",chardata"`
+}
+
+type graphMLNode struct {
+	ID   string        `xml:"id,attr"`
+	Data []graphMLData `xml:"data"`
+}
+
+type graphMLEdge struct {
+	Source string        `xml:"source,attr"`
+	Target string        `xml:"target,attr"`
+	Data   []graphMLData `xml:"data"`
+}
+
+func printGraphML(w io.Writer, g *relationGraph, edges []graphEdge) error {
+	doc := graphMLDocument{
+		Xmlns: "http://graphml.graphdrawing.org/xmlns",
+		Keys: []graphMLKey{
+			{ID: "kind", For: "node", AttrName: "kind", AttrType: "string"},
+			{ID: "namespace", For: "node", AttrName: "namespace", AttrType: "string"},
+			{ID: "name", For: "node", AttrName: "name", AttrType: "string"},
+			{ID: "depth", For: "node", AttrName: "depth", AttrType: "int"},
+			{ID: "missing", For: "node", AttrName: "missing", AttrType: "boolean"},
+			{ID: "relation", For: "edge", AttrName: "relation", AttrType: "string"},
+		},
+	}
+	doc.Graph.ID = g.nodes[0].ref.String()
+	doc.Graph.EdgeDefault = "directed"
+
+	for _, node := range g.nodes {
+		doc.Graph.Nodes = append(doc.Graph.Nodes, graphMLNode{
+			ID: node.ref.String(),
+			Data: []graphMLData{
+				{Key: "kind", Value: strings.ToLower(node.ref.Kind)},
+				{Key: "namespace", Value: node.ref.Namespace},
+				{Key: "name", Value: node.ref.Name},
+				{Key: "depth", Value: fmt.Sprint(node.depth)},
+				{Key: "missing", Value: fmt.Sprint(node.missing())},
+			},
+		})
+	}
+	for _, edge := range edges {
+		doc.Graph.Edges = append(doc.Graph.Edges, graphMLEdge{
+			Source: edge.from.String(),
+			Target: edge.to.String(),
+			Data:   []graphMLData{{Key: "relation", Value: edge.relation}},
+		})
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling to GraphML: %w", err)
+	}
+	fmt.Fprintln(w, xml.Header+string(data))
+	return nil
+}
+
+type graphJSONNode struct {
+	Ref       string `json:"ref"`
+	Kind      string `json:"kind"`
+	Namespace string `json:"namespace"`
+	Name      string `json:"name"`
+	Title     string `json:"title,omitempty"`
+	Depth     int    `json:"depth"`
+	Missing   bool   `json:"missing,omitempty"`
+}
+
+type graphJSONEdge struct {
+	Source   string `json:"source"`
+	Target   string `json:"target"`
+	Relation string `json:"relation"`
+}
+
+func printGraphJSON(w io.Writer, g *relationGraph, edges []graphEdge) error {
+	output := struct {
+		Root  string          `json:"root"`
+		Nodes []graphJSONNode `json:"nodes"`
+		Edges []graphJSONEdge `json:"edges"`
+	}{Root: g.nodes[0].ref.String(), Nodes: []graphJSONNode{}, Edges: []graphJSONEdge{}}
+
+	for _, node := range g.nodes {
+		output.Nodes = append(output.Nodes, graphJSONNode{
+			Ref:       node.ref.String(),
+			Kind:      strings.ToLower(node.ref.Kind),
+			Namespace: node.ref.Namespace,
+			Name:      node.ref.Name,
+			Title:     node.entity.Metadata.Title,
+			Depth:     node.depth,
+			Missing:   node.missing(),
+		})
+	}
+	for _, edge := range edges {
+		output.Edges = append(output.Edges, graphJSONEdge{Source: edge.from.String(), Target: edge.to.String(), Relation: edge.relation})
+	}
+
+	data, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling to JSON: %w", err)
+	}
+	fmt.Fprintln(w, string(data))
+	return nil
+}
+
+// nodeLabel returns the compact ref of the node, along with its title.
+func nodeLabel(node *graphNode) string {
+	label := node.ref.Compact()
+	if title := node.entity.Metadata.Title; title != "" {
+		label += "\n" + title
+	}
+	if node.missing() {
+		label += "\n(not found)"
+	}
+	return label
+}
+
+// dotQuote quotes s as a DOT string.
+func dotQuote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s) + `"`
+}
+
+func init() {
+	graphCmd.Flags().Int("depth", 2, "Number of relations to follow from the entity, 0 for no limit")
+	graphCmd.Flags().StringSlice("relations", defaultGraphRelations, "Relation types to follow")
+	graphCmd.Flags().Bool("both", false, "Also follow the inverse relations, towards the entities pointing at the root")
+	graphCmd.Flags().String("format", "dot", fmt.Sprintf("Graph format [%s]", strings.Join(graphFormats, "|")))
+	rootCmd.AddCommand(graphCmd)
+}
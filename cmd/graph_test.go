@@ -0,0 +1,51 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/vcaldaralo/backstagectl/catalog"
+)
+
+func TestGraphRefCase(t *testing.T) {
+	serveByRefs(t,
+		component("Payments", catalog.Relation{Type: catalog.RelationDependsOn, TargetRef: "resource:default/payments-db"}),
+		catalog.Entity{Kind: "Resource", Metadata: catalog.Metadata{Namespace: "default", Name: "payments-db"},
+			Relations: []catalog.Relation{{Type: catalog.RelationDependencyOf, TargetRef: "component:default/payments"}}},
+	)
+	root, _ := catalog.ParseEntityRef("component:PAYMENTS", catalog.EntityRef{})
+	relations := []string{catalog.RelationDependsOn}
+	g, err := walkRelations(context.Background(), root, append(relations, catalog.RelationDependencyOf), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	edges := declaredEdges(g.edges, relations)
+	if len(g.nodes) != 2 || len(edges) != 1 {
+		t.Fatalf("got %d nodes and %d edges, want 2 and 1", len(g.nodes), len(edges))
+	}
+
+	tests := []struct {
+		name  string
+		print func(*bytes.Buffer) error
+		want  string
+	}{
+		{"mermaid", func(b *bytes.Buffer) error { return printMermaid(b, g, edges) }, "  n0 -->|dependsOn| n1\n"},
+		{"dot", func(b *bytes.Buffer) error { return printDot(b, g, edges) }, `  "component:default/Payments" -> "resource:default/payments-db" [label="dependsOn"];` + "\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			if err := tt.print(&out); err != nil {
+				t.Fatal(err)
+			}
+			if !strings.Contains(out.String(), tt.want) {
+				t.Errorf("output doesn't contain %q:\n%s", tt.want, out.String())
+			}
+			if strings.Contains(out.String(), "component:default/payments\"") {
+				t.Errorf("output has a node spelled as the relation:\n%s", out.String())
+			}
+		})
+	}
+}
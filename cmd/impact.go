@@ -0,0 +1,105 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vcaldaralo/backstagectl/catalog"
+)
+
+// impactRelations are the relations pointing from an entity at the entities
+// affected by a change to it: its dependents, API consumers and parts.
+var impactRelations = []string{
+	catalog.RelationDependencyOf,
+	catalog.RelationAPIConsumedBy,
+	catalog.RelationHasPart,
+}
+
+var impactCmd = &cobra.Command{
+	Use:   "impact <entityRef>",
+	Short: "List the entities affected by a change to an entity",
+	Long: `List the entities affected by a change to an entity, e.g. before
+deprecating it: those depending on it, consuming it when it is an API, or
+part of it, transitively. Each entity is reported with its owner and the
+path of relations leading from it to the root.`,
+	Args: usageArgs(cobra.ExactArgs(1)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		depth, _ := cmd.Flags().GetInt("depth")
+		outputFormat, err := getOutputFormat(cmd)
+		if err != nil {
+			return err
+		}
+		if depth < 0 {
+			return fmt.Errorf("%w: --depth must not be negative", errInvalidArgs)
+		}
+		root, err := catalog.ParseEntityRef(args[0], catalog.EntityRef{})
+		if err != nil {
+			return err
+		}
+
+		if err := initAuth(cmd); err != nil {
+			return err
+		}
+
+		g, err := walkRelations(cmd.Context(), root, impactRelations, depth)
+		if err != nil {
+			return err
+		}
+
+		// Entities are listed by distance from the root
+		t := table{header: []string{"NAMESPACE", "NAME", "KIND", "OWNER", "DEPTH", "PATH"}, ordered: true}
+		for _, node := range g.nodes[1:] {
+			entity := node.entity
+			if node.missing() {
+				entity = catalog.Entity{Kind: node.ref.Kind, Metadata: catalog.Metadata{Namespace: node.ref.Namespace, Name: node.ref.Name}}
+			}
+			item := impactedEntity{
+				Namespace: node.ref.Namespace,
+				Name:      node.ref.Name,
+				Kind:      entity.Kind,
+				Owner:     strings.Join(refsOrSpec(entity, catalog.RelationOwnedBy, "", "owner"), ","),
+				Depth:     node.depth,
+				Path:      impactPath(g, node),
+			}
+			t.appendItem(entity, item, item.Namespace, item.Name, item.Kind, item.Owner, strconv.Itoa(item.Depth), item.Path)
+		}
+		return formatOutput(t, outputFormat)
+	},
+}
+
+// impactedEntity is printed by the json, jsonl and yaml formats for each
+// entity affected by the change.
+type impactedEntity struct {
+	Namespace string `json:"namespace" yaml:"namespace"`
+	Name      string `json:"name" yaml:"name"`
+	Kind      string `json:"kind" yaml:"kind"`
+	Owner     string `json:"owner" yaml:"owner"`
+	Depth     int    `json:"depth" yaml:"depth"`
+	Path      string `json:"path" yaml:"path"`
+}
+
+// impactPath returns the relations leading from the node to the root, in
+// the direction they are declared, e.g.
+// component:web -dependsOn-> component:payments -dependsOn-> resource:db.
+func impactPath(g *relationGraph, node *graphNode) string {
+	path := node.ref.Compact()
+	visited := map[*graphNode]bool{node: true}
+	for node.via != nil {
+		next := g.node(node.via.from)
+		if next == nil || visited[next] {
+			break
+		}
+		visited[next] = true
+		path += fmt.Sprintf(" -%s-> %s", catalog.InverseRelation(node.via.relation), next.ref.Compact())
+		node = next
+	}
+	return path
+}
+
+func init() {
+	impactCmd.Flags().Int("depth", 0, "Number of relations to follow from the entity, 0 for no limit")
+	addOutputFlag(impactCmd)
+	rootCmd.AddCommand(impactCmd)
+}
@@ -0,0 +1,123 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+
+	"github.com/vcaldaralo/backstagectl/catalog"
+)
+
+// serveByRefs points the client at a fake catalog answering by-refs
+// requests from entities, matching refs case insensitively as Backstage does.
+func serveByRefs(t *testing.T, entities ...catalog.Entity) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request byRefs
+		if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		items := make([]*catalog.Entity, len(request.EntityRefs))
+		for i, ref := range request.EntityRefs {
+			parsed, err := catalog.ParseEntityRef(ref, catalog.EntityRef{})
+			if err != nil {
+				continue
+			}
+			for j := range entities {
+				if entities[j].Ref().Equal(parsed) {
+					items[i] = &entities[j]
+				}
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"items": items})
+	}))
+	t.Cleanup(server.Close)
+
+	var err error
+	if client, err = catalog.NewClient(server.URL); err != nil {
+		t.Fatal(err)
+	}
+}
+
+type byRefs struct {
+	EntityRefs []string `json:"entityRefs"`
+}
+
+func component(name string, relations ...catalog.Relation) catalog.Entity {
+	return catalog.Entity{Kind: "Component", Metadata: catalog.Metadata{Namespace: "default", Name: name}, Relations: relations}
+}
+
+func TestImpactRefCase(t *testing.T) {
+	dependencyOf := func(ref string) catalog.Relation {
+		return catalog.Relation{Type: catalog.RelationDependencyOf, TargetRef: ref}
+	}
+
+	tests := []struct {
+		name     string
+		entities []catalog.Entity
+		root     string
+		want     []string
+	}{
+		{
+			name: "root spelled differently",
+			entities: []catalog.Entity{
+				component("Payments", dependencyOf("component:default/ledger")),
+				component("ledger"),
+			},
+			root: "component:payments",
+			want: []string{"component:ledger -dependsOn-> component:Payments"},
+		},
+		{
+			name: "cycle back to the root",
+			entities: []catalog.Entity{
+				component("payments", dependencyOf("component:default/ledger")),
+				component("ledger", dependencyOf("component:default/payments")),
+			},
+			root: "component:Payments",
+			want: []string{"component:ledger -dependsOn-> component:payments"},
+		},
+		{
+			name: "relation spelled differently",
+			entities: []catalog.Entity{
+				component("payments", dependencyOf("component:default/Ledger")),
+				component("ledger", dependencyOf("component:default/Payments")),
+				component("web", dependencyOf("component:default/LEDGER")),
+			},
+			root: "component:web",
+			want: []string{
+				"component:ledger -dependsOn-> component:web",
+				"component:payments -dependsOn-> component:ledger -dependsOn-> component:web",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			serveByRefs(t, tt.entities...)
+			root, err := catalog.ParseEntityRef(tt.root, catalog.EntityRef{})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			g, err := walkRelations(context.Background(), root, impactRelations, 0)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var got []string
+			for _, node := range g.nodes[1:] {
+				got = append(got, impactPath(g, node))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("impact paths = %q, want %q", got, tt.want)
+			}
+			for _, edge := range g.edges {
+				if g.node(edge.from).ref != edge.from || g.node(edge.to).ref != edge.to {
+					t.Errorf("edge %s -> %s isn't spelled as its nodes", edge.from, edge.to)
+				}
+			}
+		})
+	}
+}
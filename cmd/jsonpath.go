@@ -0,0 +1,421 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// jsonPath is a parsed kubectl style JSONPath template, e.g.
+// '{.metadata.name}{"\t"}{.spec.owner}{"\n"}'. It supports fields, with dots
+// in keys escaped as in .metadata.annotations.backstage\.io/orphan or quoted
+// as in ['backstage.io/orphan'], indexes, [*] and .*, recursive descent with ..,
+// filters like [?(@.type=="ownedBy")], string literals and range/end blocks.
+type jsonPath struct {
+	nodes []templateNode
+}
+
+type templateNode struct {
+	kind string     // text, path or range
+	text string     // text or literal printed as is
+	path []pathStep // expression printed, or iterated over by range
+	body []templateNode
+}
+
+type pathStep struct {
+	kind   string // field, index, wildcard, recursive or filter
+	field  string
+	index  int
+	filter *pathFilter
+}
+
+type pathFilter struct {
+	path  []pathStep
+	op    string // "", == or !=
+	value string
+}
+
+func parseJSONPath(template string) (*jsonPath, error) {
+	nodes, _, err := parseTemplateNodes(template, false)
+	if err != nil {
+		return nil, err
+	}
+	return &jsonPath{nodes: nodes}, nil
+}
+
+// parseTemplateNodes parses nodes until the end of s or, inside a range,
+// until the matching {end}, returning what follows it.
+func parseTemplateNodes(s string, inRange bool) ([]templateNode, string, error) {
+	var nodes []templateNode
+	for s != "" {
+		open := strings.Index(s, "{")
+		if open < 0 {
+			nodes = append(nodes, templateNode{kind: "text", text: s})
+			s = ""
+			break
+		}
+		if open > 0 {
+			nodes = append(nodes, templateNode{kind: "text", text: s[:open]})
+		}
+		close := matchingBrace(s, open)
+		if close < 0 {
+			return nil, "", fmt.Errorf("unclosed { in jsonpath template")
+		}
+		expr := strings.TrimSpace(s[open+1 : close])
+		s = s[close+1:]
+
+		switch {
+		case expr == "end":
+			if !inRange {
+				return nil, "", fmt.Errorf("{end} without {range} in jsonpath template")
+			}
+			return nodes, s, nil
+		case strings.HasPrefix(expr, "range "):
+			path, err := parsePath(strings.TrimSpace(strings.TrimPrefix(expr, "range ")))
+			if err != nil {
+				return nil, "", err
+			}
+			body, rest, err := parseTemplateNodes(s, true)
+			if err != nil {
+				return nil, "", err
+			}
+			s = rest
+			nodes = append(nodes, templateNode{kind: "range", path: path, body: body})
+		case strings.HasPrefix(expr, `"`):
+			text, err := strconv.Unquote(expr)
+			if err != nil {
+				return nil, "", fmt.Errorf("invalid string literal %s in jsonpath template", expr)
+			}
+			nodes = append(nodes, templateNode{kind: "text", text: text})
+		default:
+			path, err := parsePath(expr)
+			if err != nil {
+				return nil, "", err
+			}
+			nodes = append(nodes, templateNode{kind: "path", path: path})
+		}
+	}
+	if inRange {
+		return nil, "", fmt.Errorf("{range} without {end} in jsonpath template")
+	}
+	return nodes, "", nil
+}
+
+// matchingBrace returns the index of the } closing the { at open, skipping
+// quoted strings.
+func matchingBrace(s string, open int) int {
+	depth := 0
+	var quote byte
+	for i := open; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == '\\' {
+				i++
+			} else if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '{':
+			depth++
+		case c == '}':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// parsePath parses an expression such as .spec.owner or
+// .relations[?(@.type=="ownedBy")].targetRef.
+func parsePath(expr string) ([]pathStep, error) {
+	s := strings.TrimPrefix(strings.TrimPrefix(expr, "$"), "@")
+	var steps []pathStep
+
+	for s != "" {
+		switch {
+		case strings.HasPrefix(s, ".."):
+			name, rest := readField(s[2:])
+			if name == "" {
+				return nil, fmt.Errorf("missing field name after .. in %q", expr)
+			}
+			steps = append(steps, pathStep{kind: "recursive", field: name})
+			s = rest
+		case s[0] == '.':
+			name, rest := readField(s[1:])
+			if name == "*" && strings.HasPrefix(s, ".*") {
+				steps = append(steps, pathStep{kind: "wildcard"})
+			} else if name != "" {
+				steps = append(steps, pathStep{kind: "field", field: name})
+			}
+			s = rest
+		case s[0] == '[':
+			end := matchingBracket(s)
+			if end < 0 {
+				return nil, fmt.Errorf("unclosed [ in %q", expr)
+			}
+			step, err := parseBracket(strings.TrimSpace(s[1:end]), expr)
+			if err != nil {
+				return nil, err
+			}
+			steps = append(steps, step)
+			s = s[end+1:]
+		default:
+			return nil, fmt.Errorf("unexpected %q in %q", s, expr)
+		}
+	}
+	return steps, nil
+}
+
+// readField reads a field name up to the next unescaped . or [.
+func readField(s string) (string, string) {
+	var name strings.Builder
+	for i := 0; i < len(s); i++ {
+		switch c := s[i]; {
+		case c == '\\' && i+1 < len(s):
+			i++
+			name.WriteByte(s[i])
+		case c == '.' || c == '[':
+			return name.String(), s[i:]
+		default:
+			name.WriteByte(c)
+		}
+	}
+	return name.String(), ""
+}
+
+func matchingBracket(s string) int {
+	var quote byte
+	for i := 1; i < len(s); i++ {
+		switch c := s[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == ']':
+			return i
+		}
+	}
+	return -1
+}
+
+func parseBracket(content, expr string) (pathStep, error) {
+	switch {
+	case content == "*":
+		return pathStep{kind: "wildcard"}, nil
+	case strings.HasPrefix(content, "'") || strings.HasPrefix(content, `"`):
+		return pathStep{kind: "field", field: strings.Trim(content, `'"`)}, nil
+	case strings.HasPrefix(content, "?(") && strings.HasSuffix(content, ")"):
+		filter, err := parseFilter(content[2:len(content)-1], expr)
+		if err != nil {
+			return pathStep{}, err
+		}
+		return pathStep{kind: "filter", filter: filter}, nil
+	}
+	index, err := strconv.Atoi(content)
+	if err != nil {
+		return pathStep{}, fmt.Errorf("unsupported subscript [%s] in %q", content, expr)
+	}
+	return pathStep{kind: "index", index: index}, nil
+}
+
+func parseFilter(content, expr string) (*pathFilter, error) {
+	filter := &pathFilter{}
+	left := content
+	for _, op := range []string{"==", "!="} {
+		if l, r, found := strings.Cut(content, op); found {
+			left = l
+			filter.op = op
+			filter.value = strings.Trim(strings.TrimSpace(r), `'"`)
+			break
+		}
+	}
+	left = strings.TrimSpace(left)
+	if !strings.HasPrefix(left, "@") {
+		return nil, fmt.Errorf("filter must start with @ in %q", expr)
+	}
+	path, err := parsePath(left)
+	if err != nil {
+		return nil, err
+	}
+	filter.path = path
+	return filter, nil
+}
+
+// execute renders the template for data, a value decoded from JSON.
+func (j *jsonPath) execute(data interface{}) (string, error) {
+	var out strings.Builder
+	if err := executeNodes(&out, j.nodes, data); err != nil {
+		return "", err
+	}
+	return out.String(), nil
+}
+
+func executeNodes(out *strings.Builder, nodes []templateNode, data interface{}) error {
+	for _, node := range nodes {
+		switch node.kind {
+		case "range":
+			for _, item := range evalPath(node.path, []interface{}{data}) {
+				if err := executeNodes(out, node.body, item); err != nil {
+					return err
+				}
+			}
+		case "path":
+			values := evalPath(node.path, []interface{}{data})
+			for i, value := range values {
+				if i > 0 {
+					out.WriteByte(' ')
+				}
+				out.WriteString(formatValue(value))
+			}
+		default:
+			out.WriteString(node.text)
+		}
+	}
+	return nil
+}
+
+// evalPath applies the steps to every value and returns the values reached.
+func evalPath(steps []pathStep, values []interface{}) []interface{} {
+	for _, step := range steps {
+		var next []interface{}
+		for _, value := range values {
+			next = append(next, evalStep(step, value)...)
+		}
+		values = next
+	}
+	return values
+}
+
+func evalStep(step pathStep, value interface{}) []interface{} {
+	switch step.kind {
+	case "field":
+		if m, ok := value.(map[string]interface{}); ok {
+			if v, ok := m[step.field]; ok {
+				return []interface{}{v}
+			}
+		}
+	case "index":
+		if list, ok := value.([]interface{}); ok {
+			i := step.index
+			if i < 0 {
+				i += len(list)
+			}
+			if i >= 0 && i < len(list) {
+				return []interface{}{list[i]}
+			}
+		}
+	case "wildcard":
+		switch v := value.(type) {
+		case []interface{}:
+			return v
+		case map[string]interface{}:
+			var out []interface{}
+			for _, key := range sortedKeys(v) {
+				out = append(out, v[key])
+			}
+			return out
+		}
+	case "recursive":
+		var out []interface{}
+		walkValues(value, func(v interface{}) {
+			if m, ok := v.(map[string]interface{}); ok {
+				if found, ok := m[step.field]; ok {
+					out = append(out, found)
+				}
+			}
+		})
+		return out
+	case "filter":
+		list, ok := value.([]interface{})
+		if !ok {
+			return nil
+		}
+		var out []interface{}
+		for _, item := range list {
+			if step.filter.match(item) {
+				out = append(out, item)
+			}
+		}
+		return out
+	}
+	return nil
+}
+
+func (f *pathFilter) match(item interface{}) bool {
+	values := evalPath(f.path, []interface{}{item})
+	switch f.op {
+	case "==":
+		return len(values) > 0 && formatValue(values[0]) == f.value
+	case "!=":
+		return len(values) == 0 || formatValue(values[0]) != f.value
+	}
+	return len(values) > 0
+}
+
+func walkValues(value interface{}, visit func(interface{})) {
+	visit(value)
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(v) {
+			walkValues(v[key], visit)
+		}
+	case []interface{}:
+		for _, item := range v {
+			walkValues(item, visit)
+		}
+	}
+}
+
+// formatValue prints scalars as is and objects or lists as JSON.
+func formatValue(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// fields returns the dotted paths, at most two levels deep, holding every
+// value the template reads, e.g. metadata.name or metadata.annotations. It
+// returns false when the template may read any field, e.g. with {.} or {..name}.
+func (j *jsonPath) fields() ([]string, bool) {
+	var fields []string
+	for _, node := range j.nodes {
+		if node.kind == "text" {
+			continue
+		}
+		var path []string
+		for _, step := range node.path {
+			if step.kind != "field" || len(path) == 2 {
+				break
+			}
+			path = append(path, step.field)
+		}
+		if len(path) == 0 {
+			return nil, false
+		}
+		fields = append(fields, strings.Join(path, "."))
+	}
+	return fields, true
+}
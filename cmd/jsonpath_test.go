@@ -0,0 +1,140 @@
+package cmd
+
+import (
+	"encoding/json"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const jsonPathEntity = `{
+  "kind": "Component",
+  "metadata": {
+    "name": "payments",
+    "annotations": {"backstage.io/orphan": "true", "github.com/project-slug": "acme/payments"},
+    "tags": ["java", "payments", "gold"]
+  },
+  "spec": {"owner": "team-a", "lifecycle": "production"},
+  "relations": [
+    {"type": "ownedBy", "targetRef": "group:default/team-a"},
+    {"type": "partOf", "targetRef": "system:default/billing"},
+    {"type": "dependsOn", "targetRef": "resource:default/payments-db"}
+  ]
+}`
+
+func TestJSONPathExecute(t *testing.T) {
+	var entity interface{}
+	if err := json.Unmarshal([]byte(jsonPathEntity), &entity); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		template string
+		want     string
+	}{
+		{`{.metadata.name}`, "payments"},
+		{`$.metadata.name`, "$.metadata.name"},
+		{`{$.metadata.name}`, "payments"},
+		{`{.kind}/{.metadata.name}{"\n"}`, "Component/payments\n"},
+		{`name: {.metadata.name}, owner: {.spec.owner}`, "name: payments, owner: team-a"},
+		{`{.metadata.annotations.backstage\.io/orphan}`, "true"},
+		{`{.metadata.annotations['backstage.io/orphan']}`, "true"},
+		{`{.metadata.annotations["github.com/project-slug"]}`, "acme/payments"},
+		{`{.metadata.tags[0]}`, "java"},
+		{`{.metadata.tags[-1]}`, "gold"},
+		{`{.metadata.tags[-4]}`, ""},
+		{`{.metadata.tags[3]}`, ""},
+		{`{.metadata.tags[*]}`, "java payments gold"},
+		{`{.metadata.tags}`, `["java","payments","gold"]`},
+		{`{.spec}`, `{"lifecycle":"production","owner":"team-a"}`},
+		{`{.spec.missing}`, ""},
+		{`{.relations[?(@.type=="ownedBy")].targetRef}`, "group:default/team-a"},
+		{`{.relations[?(@.type=='partOf')].targetRef}`, "system:default/billing"},
+		{`{.relations[?(@.type!="ownedBy")].type}`, "partOf dependsOn"},
+		{`{.relations[?(@.targetRef)].type}`, "ownedBy partOf dependsOn"},
+		{`{.relations[?(@.missing)].type}`, ""},
+		{`{range .relations[*]}{.type}={.targetRef}{"\n"}{end}`, "ownedBy=group:default/team-a\npartOf=system:default/billing\ndependsOn=resource:default/payments-db\n"},
+		{`{range .metadata.tags[*]}[{@}]{end}`, "[java][payments][gold]"},
+		{`{..targetRef}`, "group:default/team-a system:default/billing resource:default/payments-db"},
+		{`{.spec.*}`, "production team-a"},
+		{`{"{literal}"}`, "{literal}"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			path, err := parseJSONPath(tt.template)
+			if err != nil {
+				t.Fatalf("parseJSONPath(%q) error: %v", tt.template, err)
+			}
+			got, err := path.execute(entity)
+			if err != nil {
+				t.Fatalf("execute error: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("execute = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestJSONPathFields(t *testing.T) {
+	tests := []struct {
+		template string
+		want     []string
+		ok       bool
+	}{
+		{`{.metadata.name}`, []string{"metadata.name"}, true},
+		{`{.kind}{"\t"}{.spec.owner}`, []string{"kind", "spec.owner"}, true},
+		{`{.metadata.annotations.backstage\.io/orphan}`, []string{"metadata.annotations"}, true},
+		{`{.metadata.annotations['backstage.io/orphan']}`, []string{"metadata.annotations"}, true},
+		{`{.metadata.tags[-1]}`, []string{"metadata.tags"}, true},
+		{`{.relations[?(@.type=="ownedBy")].targetRef}`, []string{"relations"}, true},
+		{`{range .relations[*]}{.type}{"\n"}{end}`, []string{"relations"}, true},
+		{`{.metadata..name}`, []string{"metadata"}, true},
+		{`plain text`, nil, true},
+		{`{..name}`, nil, false},
+		{`{.}`, nil, false},
+		{`{@}`, nil, false},
+		{`{.*}`, nil, false},
+		{`{[0]}`, nil, false},
+		{`{.metadata.name}{..targetRef}`, nil, false},
+		{`{range ..relations}{.type}{end}`, nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			path, err := parseJSONPath(tt.template)
+			if err != nil {
+				t.Fatalf("parseJSONPath(%q) error: %v", tt.template, err)
+			}
+			got, ok := path.fields()
+			if ok != tt.ok || !slices.Equal(got, tt.want) {
+				t.Errorf("fields() = %v, %v, want %v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestJSONPathErrors(t *testing.T) {
+	tests := []struct {
+		template string
+		err      string
+	}{
+		{`{.metadata.name`, "unclosed {"},
+		{`{.metadata.tags[0}`, "unclosed ["},
+		{`{end}`, "{end} without {range}"},
+		{`{range .relations[*]}{.type}`, "{range} without {end}"},
+		{`{"unterminated}`, "unclosed {"},
+		{`{"bad \q"}`, "invalid string literal"},
+		{`{metadata.name}`, "unexpected"},
+		{`{.metadata.tags[1:2]}`, "unsupported subscript"},
+		{`{.relations[?(.type=="ownedBy")]}`, "filter must start with @"},
+		{`{.metadata..}`, "missing field name after .."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			_, err := parseJSONPath(tt.template)
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("parseJSONPath(%q) error = %v, want it to contain %q", tt.template, err, tt.err)
+			}
+		})
+	}
+}
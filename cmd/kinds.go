@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// kindsCacheTTL is how long the kinds discovered from the catalog are reused
+// before being fetched again.
+const kindsCacheTTL = 10 * time.Minute
+
+type kindsCache struct {
+	BaseUrl   string    `json:"baseUrl"`
+	FetchedAt time.Time `json:"fetchedAt"`
+	Kinds     []string  `json:"kinds"`
+}
+
+func kindsCachePath() (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		homeDir, err := getHomeDir()
+		if err != nil {
+			return "", err
+		}
+		dir = filepath.Join(homeDir, ".cache")
+	}
+	name := regexp.MustCompile(`[^A-Za-z0-9.-]+`).ReplaceAllString(baseUrl, "_")
+	return filepath.Join(dir, "backstagectl", "kinds", name+".json"), nil
+}
+
+// catalogKinds returns the kinds of the entities in the catalog, from the
+// cache unless it is stale or refresh is set.
+func catalogKinds(ctx context.Context, refresh bool) ([]string, error) {
+	path, err := kindsCachePath()
+	if err != nil {
+		return nil, err
+	}
+
+	if !refresh {
+		if data, err := os.ReadFile(path); err == nil {
+			var cache kindsCache
+			if json.Unmarshal(data, &cache) == nil && cache.BaseUrl == baseUrl && time.Since(cache.FetchedAt) < kindsCacheTTL {
+				return cache.Kinds, nil
+			}
+		}
+	}
+
+	facets, err := client.Facets(ctx, "kind", nil)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching kinds: %w", err)
+	}
+	var kinds []string
+	for _, facet := range facets {
+		kinds = append(kinds, facet.Value)
+	}
+	sort.Strings(kinds)
+
+	// The cache is only an optimization, failing to write it is not an error
+	if data, err := json.Marshal(kindsCache{BaseUrl: baseUrl, FetchedAt: time.Now(), Kinds: kinds}); err == nil {
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err == nil {
+			os.WriteFile(path, data, 0600)
+		}
+	}
+
+	return kinds, nil
+}
+
+// kindForms returns the lowercase singular and plural spellings of kind.
+func kindForms(kind string) []string {
+	singular := strings.ToLower(kind)
+	forms := []string{singular, singular + "s"}
+	switch {
+	case strings.HasSuffix(singular, "s"), strings.HasSuffix(singular, "x"), strings.HasSuffix(singular, "z"),
+		strings.HasSuffix(singular, "ch"), strings.HasSuffix(singular, "sh"):
+		forms = append(forms, singular+"es")
+	case len(singular) > 1 && strings.HasSuffix(singular, "y") && !strings.ContainsAny(singular[len(singular)-2:len(singular)-1], "aeiou"):
+		forms = append(forms, singular[:len(singular)-1]+"ies")
+	}
+	return forms
+}
+
+func matchKind(input string, kinds []string) (string, bool) {
+	input = strings.ToLower(input)
+	for _, kind := range kinds {
+		for _, form := range kindForms(kind) {
+			if form == input {
+				return kind, true
+			}
+		}
+	}
+	return "", false
+}
+
+// resolveKind returns the kind of the catalog named by input, compared case
+// insensitively in its singular or plural form, e.g. "apis" gives "API".
+func resolveKind(ctx context.Context, input string) (string, error) {
+	kinds, err := catalogKinds(ctx, false)
+	if err != nil {
+		return "", err
+	}
+	if kind, ok := matchKind(input, kinds); ok {
+		return kind, nil
+	}
+
+	// The kind may have been added since the cache was written
+	if kinds, err = catalogKinds(ctx, true); err != nil {
+		return "", err
+	}
+	if kind, ok := matchKind(input, kinds); ok {
+		return kind, nil
+	}
+
+	return "", fmt.Errorf("%w: backstage doesn't have a kind '%s', available kinds are: %s", errInvalidArgs, input, strings.Join(kinds, ", "))
+}
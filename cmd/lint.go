@@ -0,0 +1,224 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/vcaldaralo/backstagectl/catalog"
+	"gopkg.in/yaml.v3"
+)
+
+// policy is a set of lint rules declared in a YAML file.
+type policy struct {
+	Rules []policyRule `yaml:"rules"`
+}
+
+// policyRule checks the entities matched by its selector for required
+// metadata and allowed spec values.
+type policyRule struct {
+	ruleHeader `yaml:",inline"`
+	Require    struct {
+		Annotations []string `yaml:"annotations"`
+		Labels      []string `yaml:"labels"`
+		Tags        []string `yaml:"tags"`
+		// Links are matched against the type or the title of the links.
+		Links []string `yaml:"links"`
+	} `yaml:"require"`
+	// AnnotationPatterns are regular expressions the values of annotations
+	// must match, when they are set.
+	AnnotationPatterns map[string]string `yaml:"annotationPatterns"`
+	Allowed            struct {
+		Lifecycle []string `yaml:"lifecycle"`
+		Type      []string `yaml:"type"`
+	} `yaml:"allowed"`
+
+	patterns map[string]*regexp.Regexp
+}
+
+// loadPolicy reads and validates the policy file.
+func loadPolicy(filename string) (*policy, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("%w: error reading policy file: %w", errInvalidArgs, err)
+	}
+
+	var p policy
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&p); err != nil {
+		return nil, fmt.Errorf("%w: error parsing policy file %s: %w", errInvalidArgs, filename, err)
+	}
+	if len(p.Rules) == 0 {
+		return nil, fmt.Errorf("%w: policy file %s has no rules", errInvalidArgs, filename)
+	}
+
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if err := rule.validate(i); err != nil {
+			return nil, err
+		}
+
+		rule.patterns = make(map[string]*regexp.Regexp)
+		for annotation, pattern := range rule.AnnotationPatterns {
+			re, err := regexp.Compile(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("%w: rule %s: invalid pattern for annotation %s: %w", errInvalidArgs, rule.ID, annotation, err)
+			}
+			rule.patterns[annotation] = re
+		}
+	}
+	return &p, nil
+}
+
+// check returns the findings of the rule for the entity.
+func (r *policyRule) check(entity catalog.Entity) []checkFinding {
+	if !r.Selector.matches(entity) {
+		return nil
+	}
+
+	var findings []checkFinding
+	report := func(format string, args ...interface{}) {
+		findings = append(findings, r.finding(entity, fmt.Sprintf(format, args...)))
+	}
+
+	for _, annotation := range r.Require.Annotations {
+		if _, ok := entity.Metadata.Annotations[annotation]; !ok {
+			report("missing annotation %s", annotation)
+		}
+	}
+	for _, label := range r.Require.Labels {
+		if _, ok := entity.Metadata.Labels[label]; !ok {
+			report("missing label %s", label)
+		}
+	}
+	for _, tag := range r.Require.Tags {
+		if !containsFold(entity.Metadata.Tags, tag) {
+			report("missing tag %s", tag)
+		}
+	}
+	for _, want := range r.Require.Links {
+		found := false
+		for _, link := range entity.Metadata.Links {
+			if strings.EqualFold(link.Type, want) || strings.EqualFold(link.Title, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			report("missing link %s", want)
+		}
+	}
+
+	for _, annotation := range sortedStringKeys(r.patterns) {
+		value, ok := entity.Metadata.Annotations[annotation]
+		if ok && !r.patterns[annotation].MatchString(value) {
+			report("annotation %s '%s' doesn't match %s", annotation, value, r.patterns[annotation])
+		}
+	}
+
+	if lifecycle := entity.SpecString("lifecycle"); len(r.Allowed.Lifecycle) > 0 && !containsFold(r.Allowed.Lifecycle, lifecycle) {
+		report("lifecycle '%s' is not one of %s", lifecycle, strings.Join(r.Allowed.Lifecycle, ", "))
+	}
+	if specType := entity.SpecString("type"); len(r.Allowed.Type) > 0 && !containsFold(r.Allowed.Type, specType) {
+		report("type '%s' is not one of %s", specType, strings.Join(r.Allowed.Type, ", "))
+	}
+	return findings
+}
+
+func sortedStringKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+var lintCmd = &cobra.Command{
+	Use:   "lint --policy <file> [kind|entityRef] [name]",
+	Short: "Check entities against the rules of a policy file",
+	Long: `Check entities against the rules of a policy file. Each rule selects
+entities by kind, type, lifecycle and namespace, and declares the
+annotations, labels, tags and links they require, patterns for annotation
+values and the allowed lifecycles and types:
+
+  rules:
+    - id: production-pagerduty
+      description: Production services must be on call
+      severity: error
+      selector:
+        kind: [Component]
+        type: [service]
+        lifecycle: [production]
+      require:
+        annotations: [pagerduty.com/service-id]
+        links: [dashboard]
+      annotationPatterns:
+        backstage.io/source-location: '^url:https://github\.com/acme/'
+      allowed:
+        lifecycle: [experimental, production, deprecated]
+
+The command fails with exit code 7 when a finding is at least as severe as
+--fail-on.`,
+	Args: usageArgs(cobra.MaximumNArgs(2)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		policyFile, _ := cmd.Flags().GetString("policy")
+		failOn, _ := cmd.Flags().GetString("fail-on")
+		outputFormat, err := getOutputFormat(cmd)
+		if err != nil {
+			return err
+		}
+
+		if policyFile == "" {
+			return fmt.Errorf("%w: no policy file provided, please specify one with --policy", errInvalidArgs)
+		}
+		if failOn, err = parseFailOn(failOn); err != nil {
+			return err
+		}
+		p, err := loadPolicy(policyFile)
+		if err != nil {
+			return err
+		}
+
+		if err := initAuth(cmd); err != nil {
+			return err
+		}
+
+		filter, err := parseArgs(cmd.Context(), args)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			filter = append(filter, kindsFilter(p.Rules)...)
+		}
+
+		entities, err := client.QueryEntities(cmd.Context(), catalog.Query{
+			Filter: filter,
+			Fields: outputFields(outputFormat, "kind", "metadata", "spec.type", "spec.lifecycle"),
+		})
+		if err != nil {
+			return err
+		}
+
+		var findings []checkFinding
+		for _, entity := range entities {
+			for i := range p.Rules {
+				findings = append(findings, p.Rules[i].check(entity)...)
+			}
+		}
+
+		return printFindings(findings, outputFormat, failOn)
+	},
+}
+
+func init() {
+	lintCmd.Flags().String("policy", "", "Policy file declaring the rules")
+	lintCmd.Flags().String("fail-on", "error", fmt.Sprintf("Lowest severity of the findings failing the command [%s|none]", strings.Join(severities, "|")))
+	addFindingsOutputFlag(lintCmd)
+	rootCmd.AddCommand(lintCmd)
+}
@@ -0,0 +1,510 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"iter"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/spf13/cobra"
+	"github.com/vcaldaralo/backstagectl/catalog"
+)
+
+var outputFormats = []string{"table", "wide", "json", "jsonl", "yaml", "name", "csv", "markdown"}
+
+// templateFormats are evaluated against each entity with the template given
+// after '=', e.g. jsonpath={.metadata.name}.
+var templateFormats = []string{"custom-columns", "jsonpath", "go-template"}
+
+// wideFields are the entity fields read by the wide output format, commands
+// that restrict the fetched fields add them when it is selected.
+var wideFields = []string{"kind", "spec.owner", "spec.lifecycle", "spec.system"}
+
+// table is what a command prints: a header and one row per entity. entities
+// holds the entity each row is about, which the name and wide formats read.
+// When objects is set, json and yaml print the entities themselves instead
+// of the rows, and when items is set they print the item of each row. When
+// ordered is set, rows are printed in the order they were appended, e.g. the
+// order asked from the catalog, instead of being sorted.
+// When continued is set, the rows follow others already printed, so the
+// header isn't printed again. The table and wide formats drop the NAMESPACE
+// column when every row is in the default namespace, unless keepNamespace is
+// set, e.g. because rows streamed later may not be. Commands reporting findings add the finding of
+// each row, which the sarif and junit formats print.
+type table struct {
+	header        []string
+	rows          [][]string
+	entities      []catalog.Entity
+	findings      []checkFinding
+	items         []interface{}
+	objects       bool
+	ordered       bool
+	continued     bool
+	keepNamespace bool
+}
+
+// streamBatch is the number of rows streamOutput prints at once.
+const streamBatch = 100
+
+// streams reports whether the output format can print rows in batches, so
+// rows can be printed while the next ones are fetched. The columns of the
+// table and wide formats are aligned within each batch.
+func streams(outputFormat string) bool {
+	switch name, _ := splitOutputFormat(outputFormat); name {
+	case "name", "csv", "jsonl", "jsonpath", "go-template", "table", "wide":
+		return true
+	}
+	return false
+}
+
+// streamOutput prints a row for each entity of the iterator, in batches, as
+// the entities are read. The table holds the header and the settings of the
+// output, its rows are printed in the order of the iterator.
+func streamOutput(t table, entities iter.Seq2[catalog.Entity, error], row func(catalog.Entity) []string, outputFormat string) error {
+	t.ordered = true
+	for entity, err := range entities {
+		if err != nil {
+			return err
+		}
+		t.append(entity, row(entity)...)
+		if len(t.rows) == streamBatch {
+			if err := formatOutput(t, outputFormat); err != nil {
+				return err
+			}
+			t.rows, t.entities, t.continued = nil, nil, true
+		}
+	}
+	if len(t.rows) == 0 && t.continued {
+		return nil
+	}
+	return formatOutput(t, outputFormat)
+}
+
+func (t *table) append(entity catalog.Entity, row ...string) {
+	t.entities = append(t.entities, entity)
+	t.rows = append(t.rows, row)
+}
+
+func (t *table) appendFinding(finding checkFinding, row ...string) {
+	t.append(finding.entity, row...)
+	t.findings = append(t.findings, finding)
+}
+
+func (t *table) appendItem(entity catalog.Entity, item interface{}, row ...string) {
+	t.append(entity, row...)
+	t.items = append(t.items, item)
+}
+
+// sort orders the rows, and their entities, findings and items, by their
+// columns.
+func (t *table) sort() {
+	order := make([]int, len(t.rows))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return strings.Join(t.rows[order[i]], "\t") < strings.Join(t.rows[order[j]], "\t")
+	})
+
+	rows := make([][]string, len(t.rows))
+	entities := make([]catalog.Entity, len(t.entities))
+	var findings []checkFinding
+	if t.findings != nil {
+		findings = make([]checkFinding, len(t.findings))
+	}
+	var items []interface{}
+	if t.items != nil {
+		items = make([]interface{}, len(t.items))
+	}
+	for i, j := range order {
+		rows[i] = t.rows[j]
+		entities[i] = t.entities[j]
+		if findings != nil {
+			findings[i] = t.findings[j]
+		}
+		if items != nil {
+			items[i] = t.items[j]
+		}
+	}
+	t.rows, t.entities, t.findings, t.items = rows, entities, findings, items
+}
+
+// outputFields returns the entity fields to fetch for the output format:
+// fields, plus those read by the wide and finding formats or by the template,
+// or every field (nil) when the template may read any of them.
+func outputFields(outputFormat string, fields ...string) []string {
+	name, arg := splitOutputFormat(outputFormat)
+	switch name {
+	case "wide":
+		return append(fields, wideFields...)
+	case "sarif", "junit":
+		// Findings are located with the annotations of the entities
+		return append(fields, "metadata.annotations")
+	case "custom-columns", "jsonpath":
+		if templateFields, ok := readFields(name, arg); ok {
+			return append(fields, templateFields...)
+		}
+		return nil
+	case "go-template":
+		return nil
+	}
+	return fields
+}
+
+// readFields returns the fields read by a custom-columns or jsonpath template.
+func readFields(format, arg string) ([]string, bool) {
+	var paths []*jsonPath
+	if format == "custom-columns" {
+		columns, err := parseCustomColumns(arg)
+		if err != nil {
+			return nil, false
+		}
+		for _, column := range columns {
+			paths = append(paths, column.path)
+		}
+	} else {
+		path, err := parseJSONPath(arg)
+		if err != nil {
+			return nil, false
+		}
+		paths = append(paths, path)
+	}
+
+	var fields []string
+	for _, path := range paths {
+		pathFields, ok := path.fields()
+		if !ok {
+			return nil, false
+		}
+		fields = append(fields, pathFields...)
+	}
+	return fields, true
+}
+
+func addOutputFlag(cmd *cobra.Command) {
+	cmd.Flags().StringP("output", "o", "table", fmt.Sprintf("Output format [%s|%s]",
+		strings.Join(supportedFormats(cmd), "|"), strings.Join(templateFormats, "=...|")+"=..."))
+}
+
+func splitOutputFormat(outputFormat string) (string, string) {
+	name, arg, _ := strings.Cut(outputFormat, "=")
+	return name, arg
+}
+
+// getOutputFormat returns the value of the output flag after checking it is
+// a known format with a valid template.
+func getOutputFormat(cmd *cobra.Command) (string, error) {
+	outputFormat, _ := cmd.Flags().GetString("output")
+	name, arg := splitOutputFormat(outputFormat)
+
+	var err error
+	switch name {
+	case "custom-columns":
+		_, err = parseCustomColumns(arg)
+	case "jsonpath":
+		_, err = parseJSONPath(arg)
+	case "go-template":
+		_, err = template.New("output").Parse(arg)
+	default:
+		for _, format := range supportedFormats(cmd) {
+			if outputFormat == format {
+				return outputFormat, nil
+			}
+		}
+		return "", fmt.Errorf("%w: unknown output format '%s', supported formats are: %s, %s", errInvalidArgs, outputFormat,
+			strings.Join(supportedFormats(cmd), ", "), strings.Join(templateFormats, "=..., ")+"=...")
+	}
+	if arg == "" {
+		return "", fmt.Errorf("%w: output format %s needs a template, e.g. %s=...", errInvalidArgs, name, name)
+	}
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid %s output: %w", errInvalidArgs, name, err)
+	}
+	return outputFormat, nil
+}
+
+func formatOutput(t table, outputFormat string) error {
+	if !t.ordered {
+		t.sort()
+	}
+
+	name, arg := splitOutputFormat(outputFormat)
+	switch name {
+	case "custom-columns", "jsonpath", "go-template":
+		return printTemplate(t.entities, name, arg)
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(t.structured()); err != nil {
+			return fmt.Errorf("error marshalling to JSON: %w", err)
+		}
+		return nil
+	case "jsonl":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetEscapeHTML(false)
+		for _, item := range t.structured() {
+			if err := encoder.Encode(item); err != nil {
+				return fmt.Errorf("error marshalling to JSON: %w", err)
+			}
+		}
+		return nil
+	case "yaml":
+		return printYaml(t.structured())
+	case "name":
+		seen := make(map[string]bool)
+		for _, entity := range t.entities {
+			ref := entity.Ref().String()
+			if !seen[ref] {
+				seen[ref] = true
+				fmt.Println(ref)
+			}
+		}
+		return nil
+	case "csv":
+		w := csv.NewWriter(os.Stdout)
+		if !t.continued {
+			w.Write(t.header)
+		}
+		w.WriteAll(t.rows)
+		return w.Error()
+	case "markdown":
+		printMarkdown(t.header, t.rows)
+		return nil
+	case "sarif":
+		return printSARIF(os.Stdout, t.findings)
+	case "junit":
+		return printJUnit(os.Stdout, t.findings)
+	case "wide":
+		t = t.wide()
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	defer w.Flush()
+
+	header, data := t.header, t.rows
+
+	isNamespaceDefaultOnly := !t.keepNamespace
+	for _, row := range data {
+		if len(row) > 0 && row[0] != "default" {
+			isNamespaceDefaultOnly = false
+			break
+		}
+	}
+
+	if isNamespaceDefaultOnly && len(data) > 0 {
+		if !t.continued {
+			fmt.Fprintln(w, strings.Join(header[1:], "\t"))
+		}
+		for _, row := range data {
+			if len(row) > 1 {
+				fmt.Fprintln(w, strings.Join(row[1:], "\t"))
+			}
+		}
+	} else {
+		if !t.continued {
+			fmt.Fprintln(w, strings.Join(header, "\t"))
+		}
+		for _, row := range data {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+	}
+	return nil
+}
+
+// structured returns the items printed by the json, jsonl and yaml formats.
+func (t table) structured() []interface{} {
+	output := make([]interface{}, len(t.rows))
+	if t.objects {
+		for i, entity := range t.entities {
+			output[i] = entity
+		}
+		return output
+	}
+	if t.items != nil {
+		return t.items
+	}
+
+	for i, row := range t.rows {
+		entry := make(map[string]string)
+		for j, col := range t.header {
+			if j < len(row) {
+				entry[strings.ToLower(col)] = row[j]
+			}
+		}
+		output[i] = entry
+	}
+	return output
+}
+
+// wide returns the table with the kind, owner, lifecycle and system of the
+// entities added before the URL column, unless the table already has them.
+func (t table) wide() table {
+	at := len(t.header)
+	if at > 0 && t.header[at-1] == "URL" {
+		at--
+	}
+
+	columns := []struct {
+		header string
+		value  func(catalog.Entity) string
+	}{
+		{"KIND", func(e catalog.Entity) string { return e.Kind }},
+		{"OWNER", func(e catalog.Entity) string { return e.SpecString("owner") }},
+		{"LIFECYCLE", func(e catalog.Entity) string { return e.SpecString("lifecycle") }},
+		{"SYSTEM", func(e catalog.Entity) string { return e.SpecString("system") }},
+	}
+	var headers []string
+	var values []func(catalog.Entity) string
+	for _, column := range columns {
+		if !containsFold(t.header, column.header) {
+			headers = append(headers, column.header)
+			values = append(values, column.value)
+		}
+	}
+
+	insert := func(cells []string, added ...string) []string {
+		out := append([]string{}, cells[:at]...)
+		out = append(out, added...)
+		return append(out, cells[at:]...)
+	}
+
+	wide := table{header: insert(t.header, headers...), entities: t.entities}
+	for i, row := range t.rows {
+		added := make([]string, len(values))
+		for j, value := range values {
+			added[j] = value(t.entities[i])
+		}
+		wide.rows = append(wide.rows, insert(row, added...))
+	}
+	return wide
+}
+
+func printMarkdown(header []string, rows [][]string) {
+	escape := func(cells []string) string {
+		escaped := make([]string, len(cells))
+		for i, cell := range cells {
+			escaped[i] = strings.ReplaceAll(strings.ReplaceAll(cell, "|", `\|`), "\n", " ")
+		}
+		return "| " + strings.Join(escaped, " | ") + " |"
+	}
+
+	separator := make([]string, len(header))
+	for i := range separator {
+		separator[i] = "---"
+	}
+
+	fmt.Println(escape(header))
+	fmt.Println("|" + strings.Join(separator, "|") + "|")
+	for _, row := range rows {
+		fmt.Println(escape(row))
+	}
+}
+
+type customColumn struct {
+	header string
+	path   *jsonPath
+}
+
+// parseCustomColumns parses a spec like NAME:.metadata.name,OWNER:.spec.owner.
+func parseCustomColumns(spec string) ([]customColumn, error) {
+	var columns []customColumn
+	for _, column := range strings.Split(spec, ",") {
+		header, expr, found := strings.Cut(column, ":")
+		if !found || header == "" || expr == "" {
+			return nil, fmt.Errorf("column %q must be of the form HEADER:.path", column)
+		}
+		if !strings.HasPrefix(expr, "{") {
+			expr = "{" + expr + "}"
+		}
+		path, err := parseJSONPath(expr)
+		if err != nil {
+			return nil, err
+		}
+		columns = append(columns, customColumn{header: header, path: path})
+	}
+	return columns, nil
+}
+
+// printTemplate prints the entities with a template format, evaluated
+// against each entity as a JSON document.
+func printTemplate(entities []catalog.Entity, format, arg string) error {
+	var columns []customColumn
+	var jsonPathTemplate *jsonPath
+	var goTemplate *template.Template
+	var err error
+
+	switch format {
+	case "custom-columns":
+		columns, err = parseCustomColumns(arg)
+	case "jsonpath":
+		jsonPathTemplate, err = parseJSONPath(arg)
+	case "go-template":
+		goTemplate, err = template.New("output").Parse(arg)
+	}
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	defer w.Flush()
+
+	if columns != nil {
+		headers := make([]string, len(columns))
+		for i, column := range columns {
+			headers[i] = column.header
+		}
+		fmt.Fprintln(w, strings.Join(headers, "\t"))
+	}
+
+	for _, entity := range entities {
+		document, err := toDocument(entity)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case columns != nil:
+			values := make([]string, len(columns))
+			for i, column := range columns {
+				if values[i], err = column.path.execute(document); err != nil {
+					return err
+				}
+				if values[i] == "" {
+					values[i] = "<none>"
+				}
+			}
+			fmt.Fprintln(w, strings.Join(values, "\t"))
+		case jsonPathTemplate != nil:
+			out, err := jsonPathTemplate.execute(document)
+			if err != nil {
+				return err
+			}
+			fmt.Fprint(w, out)
+		default:
+			if err := goTemplate.Execute(w, document); err != nil {
+				return fmt.Errorf("error executing go-template: %w", err)
+			}
+		}
+	}
+	return nil
+}
+
+// toDocument converts the entity into the generic value decoded from its JSON
+// form, which templates are evaluated against.
+func toDocument(entity catalog.Entity) (interface{}, error) {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling to JSON: %w", err)
+	}
+	var document interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("error unmarshalling JSON: %w", err)
+	}
+	return document, nil
+}
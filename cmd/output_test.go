@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/vcaldaralo/backstagectl/catalog"
+)
+
+// captureStdout returns what run prints on the standard output.
+func captureStdout(t *testing.T, run func() error) string {
+	t.Helper()
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+
+	output := make(chan string)
+	go func() {
+		data, _ := io.ReadAll(r)
+		output <- string(data)
+	}()
+	runErr := run()
+	w.Close()
+	if runErr != nil {
+		t.Fatal(runErr)
+	}
+	return <-output
+}
+
+func TestStreamTableOutput(t *testing.T) {
+	var entities []catalog.Entity
+	for i := range streamBatch + 20 {
+		entities = append(entities, component(fmt.Sprintf("c%03d", i)))
+	}
+	seq := func(yield func(catalog.Entity, error) bool) {
+		for _, entity := range entities {
+			if !yield(entity, nil) {
+				return
+			}
+		}
+	}
+	row := func(entity catalog.Entity) []string {
+		return []string{entity.Metadata.Namespace, entity.Metadata.Name}
+	}
+
+	tests := []struct {
+		name          string
+		keepNamespace bool
+		header        string
+		last          string
+	}{
+		{"default namespace only", false, "NAME", "c119"},
+		{"other namespaces", true, "NAMESPACE NAME", "default c119"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			table := table{header: []string{"NAMESPACE", "NAME"}, keepNamespace: tt.keepNamespace}
+			output := captureStdout(t, func() error { return streamOutput(table, seq, row, "table") })
+			lines := strings.Split(strings.TrimSuffix(output, "\n"), "\n")
+			if len(lines) != len(entities)+1 {
+				t.Fatalf("printed %d lines, want %d:\n%s", len(lines), len(entities)+1, output)
+			}
+			if strings.TrimSpace(lines[0]) != tt.header || lines[len(lines)-1] != tt.last {
+				t.Errorf("header %q and last line %q, want %q and %q", lines[0], lines[len(lines)-1], tt.header, tt.last)
+			}
+		})
+	}
+}
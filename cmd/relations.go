@@ -0,0 +1,282 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/vcaldaralo/backstagectl/catalog"
+)
+
+// relationFields are the entity fields read while walking relations.
+var relationFields = []string{"kind", "metadata.namespace", "metadata.name", "metadata.title",
+	"spec.type", "spec.owner", "spec.lifecycle", "spec.system", "relations"}
+
+// refsBatch is the number of refs fetched by a single by-refs request.
+const refsBatch = 500
+
+// relationGraph is the part of the catalog reached from a root entity by
+// following relations.
+type relationGraph struct {
+	// nodes are in the order they were reached, the root first.
+	nodes []*graphNode
+	edges []graphEdge
+	// byRef indexes the nodes by the Key of their ref.
+	byRef map[string]*graphNode
+}
+
+type graphNode struct {
+	ref catalog.EntityRef
+	// entity is the zero Entity when the catalog doesn't have it.
+	entity catalog.Entity
+	depth  int
+	// via is the relation the node was first reached through, nil for the
+	// root. Following via back gives the shortest path to the root.
+	via *graphEdge
+}
+
+// graphEdge is a relation found on the entity from, pointing at to.
+type graphEdge struct {
+	from     catalog.EntityRef
+	to       catalog.EntityRef
+	relation string
+}
+
+func (n *graphNode) missing() bool {
+	return n.entity.Kind == ""
+}
+
+// walkRelations fetches the entities reached from root by following the
+// relations of the given types, level by level, up to depth relations away
+// from the root, or without limit when depth is 0. The refs of the nodes and
+// edges are spelled as the names of the entities in the catalog, whatever
+// the case of the root and of the relations.
+func walkRelations(ctx context.Context, root catalog.EntityRef, types []string, depth int) (*relationGraph, error) {
+	g := &relationGraph{byRef: map[string]*graphNode{}}
+	seenEdges := map[string]bool{}
+	level := []*graphNode{g.add(root, 0, nil)}
+
+	for len(level) > 0 {
+		if err := fetchNodes(ctx, level); err != nil {
+			return nil, err
+		}
+		if g.nodes[0].missing() {
+			return nil, fmt.Errorf("%w: no entity %s in the catalog", catalog.ErrNotFound, root)
+		}
+		for _, node := range level {
+			if !node.missing() {
+				node.ref = node.entity.Ref()
+			}
+		}
+
+		var next []*graphNode
+		for _, node := range level {
+			if depth > 0 && node.depth >= depth {
+				continue
+			}
+			for _, relation := range node.entity.Relations {
+				if !containsFold(types, relation.Type) {
+					continue
+				}
+				target, err := catalog.ParseEntityRef(relation.TargetRef, catalog.EntityRef{})
+				if err != nil {
+					continue
+				}
+
+				edge := graphEdge{from: node.ref, to: target, relation: relation.Type}
+				key := edge.from.Key() + " " + strings.ToLower(edge.relation) + " " + edge.to.Key()
+				if seenEdges[key] {
+					continue
+				}
+				seenEdges[key] = true
+				g.edges = append(g.edges, edge)
+
+				if g.node(target) == nil {
+					via := edge
+					next = append(next, g.add(target, node.depth+1, &via))
+				}
+			}
+		}
+		level = next
+	}
+
+	for i := range g.edges {
+		g.canonical(&g.edges[i])
+	}
+	for _, node := range g.nodes {
+		if node.via != nil {
+			g.canonical(node.via)
+		}
+	}
+	return g, nil
+}
+
+func (g *relationGraph) add(ref catalog.EntityRef, depth int, via *graphEdge) *graphNode {
+	node := &graphNode{ref: ref, depth: depth, via: via}
+	g.nodes = append(g.nodes, node)
+	g.byRef[ref.Key()] = node
+	return node
+}
+
+// node returns the node of the ref, whatever its case, or nil.
+func (g *relationGraph) node(ref catalog.EntityRef) *graphNode {
+	return g.byRef[ref.Key()]
+}
+
+// canonical replaces the refs of the edge with those of its nodes.
+func (g *relationGraph) canonical(edge *graphEdge) {
+	if from := g.node(edge.from); from != nil {
+		edge.from = from.ref
+	}
+	if to := g.node(edge.to); to != nil {
+		edge.to = to.ref
+	}
+}
+
+// fetchNodes sets the entity of the nodes from the catalog.
+func fetchNodes(ctx context.Context, nodes []*graphNode) error {
+	for start := 0; start < len(nodes); start += refsBatch {
+		batch := nodes[start:min(start+refsBatch, len(nodes))]
+		refs := make([]string, len(batch))
+		for i, node := range batch {
+			refs[i] = node.ref.String()
+		}
+
+		entities, err := client.GetEntitiesByRefs(ctx, refs, relationFields)
+		if err != nil {
+			return err
+		}
+		for i, node := range batch {
+			if i < len(entities) {
+				node.entity = entities[i]
+			}
+		}
+	}
+	return nil
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+// stronglyConnected returns the strongly connected components of the graph
+// given by its adjacency lists, with Tarjan's algorithm. Components are
+// listed in reverse topological order, their nodes in order of discovery.
+func stronglyConnected(nodes []string, adjacent map[string][]string) [][]string {
+	index := make(map[string]int)
+	lowlink := make(map[string]int)
+	onStack := make(map[string]bool)
+	var stack []string
+	var components [][]string
+
+	var visit func(node string)
+	visit = func(node string) {
+		index[node] = len(index)
+		lowlink[node] = index[node]
+		stack = append(stack, node)
+		onStack[node] = true
+
+		for _, next := range adjacent[node] {
+			if _, visited := index[next]; !visited {
+				visit(next)
+				lowlink[node] = min(lowlink[node], lowlink[next])
+			} else if onStack[next] {
+				lowlink[node] = min(lowlink[node], index[next])
+			}
+		}
+
+		if lowlink[node] == index[node] {
+			var component []string
+			for {
+				last := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				onStack[last] = false
+				component = append(component, last)
+				if last == node {
+					break
+				}
+			}
+			for i, j := 0, len(component)-1; i < j; i, j = i+1, j-1 {
+				component[i], component[j] = component[j], component[i]
+			}
+			components = append(components, component)
+		}
+	}
+
+	for _, node := range nodes {
+		if _, visited := index[node]; !visited {
+			visit(node)
+		}
+	}
+	return components
+}
+
+// shortestPath returns the nodes of the shortest path of at least one
+// relation leading from start to end through the given nodes only, both
+// included, or nil when there is none. When end is start, it is the shortest
+// cycle through start.
+func shortestPath(start, end string, within map[string]bool, adjacent map[string][]string) []string {
+	previous := map[string]string{}
+	queue := []string{start}
+	for len(queue) > 0 {
+		node := queue[0]
+		queue = queue[1:]
+		for _, next := range adjacent[node] {
+			if !within[next] {
+				continue
+			}
+			if next == end {
+				path := []string{end}
+				for at := node; at != start; at = previous[at] {
+					path = append(path, at)
+				}
+				path = append(path, start)
+				for i, j := 0, len(path)-1; i < j; i, j = i+1, j-1 {
+					path[i], path[j] = path[j], path[i]
+				}
+				return path
+			}
+			if _, seen := previous[next]; !seen && next != start {
+				previous[next] = node
+				queue = append(queue, next)
+			}
+		}
+	}
+	return nil
+}
+
+// coveringCycle returns a closed path going through every node of a strongly
+// connected component, starting and ending at its first node, made of the
+// shortest paths between the nodes in order. It is nil when the component is
+// a single node without a relation to itself.
+func coveringCycle(component []string, adjacent map[string][]string) []string {
+	within := make(map[string]bool)
+	for _, node := range component {
+		within[node] = true
+	}
+
+	cycle := []string{component[0]}
+	visited := map[string]bool{component[0]: true}
+	extend := func(to string) bool {
+		path := shortestPath(cycle[len(cycle)-1], to, within, adjacent)
+		for _, node := range path[min(1, len(path)):] {
+			visited[node] = true
+		}
+		cycle = append(cycle, path[min(1, len(path)):]...)
+		return path != nil
+	}
+	for _, node := range component[1:] {
+		if !visited[node] && !extend(node) {
+			return nil
+		}
+	}
+	if !extend(component[0]) {
+		return nil
+	}
+	return cycle
+}
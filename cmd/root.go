@@ -1,10 +1,24 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/spf13/cobra"
+	"github.com/vcaldaralo/backstagectl/catalog"
+)
+
+var (
+	contextName    string
+	verbosity      int
+	timeout        time.Duration
+	requestTimeout time.Duration
+	maxRetries     int
+	// cancelTimeout releases the overall timeout set by initAuth
+	cancelTimeout context.CancelFunc = func() {}
 )
 
 var rootCmd = &cobra.Command{
@@ -13,11 +27,35 @@ var rootCmd = &cobra.Command{
 	Long: `backstagectl is a command line interface tool that allows you to 
 interact with Backstage API. You can fetch information 
 about entities, APIs, and other entities.`,
+	// Errors are printed by Execute
+	SilenceErrors: true,
+	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+		// Arguments and flags are valid once we get here, errors returned
+		// from now on aren't caused by a wrong invocation
+		cmd.SilenceUsage = true
+	},
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&contextName, "context", "", "Name of the config context to use (defaults to current-context)")
+	rootCmd.PersistentFlags().CountVarP(&verbosity, "verbose", "v", "Log every HTTP request to stderr, repeat (-vv) to dump request and response bodies")
+	rootCmd.PersistentFlags().DurationVar(&timeout, "timeout", 0, "Maximum duration of the whole command, e.g. 5m (0 means no limit)")
+	rootCmd.PersistentFlags().DurationVar(&requestTimeout, "request-timeout", 30*time.Second, "Maximum duration of a single HTTP request (0 means no limit)")
+	rootCmd.PersistentFlags().IntVar(&maxRetries, "max-retries", catalog.DefaultRetryPolicy.MaxRetries, "Number of retries of requests failing with 429, 5xx or connection errors")
+	rootCmd.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return fmt.Errorf("%w: %w", errInvalidArgs, err)
+	})
 }
 
 func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+	cmd, err := rootCmd.ExecuteC()
+	cancelTimeout()
+	// The root command doesn't run, its errors are unknown commands
+	if err != nil && cmd == rootCmd && !errors.Is(err, errInvalidArgs) {
+		err = fmt.Errorf("%w: %w", errInvalidArgs, err)
+	}
+	if err != nil {
+		printError(os.Stderr, err)
+		os.Exit(exitCode(err))
 	}
 }
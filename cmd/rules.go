@@ -0,0 +1,310 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/google/cel-go/cel"
+	"github.com/google/cel-go/common/types"
+	"github.com/google/cel-go/common/types/ref"
+	"github.com/spf13/cobra"
+	"github.com/vcaldaralo/backstagectl/catalog"
+	"gopkg.in/yaml.v3"
+)
+
+// ruleSet is a set of expression rules declared in a YAML file.
+type ruleSet struct {
+	Rules []expressionRule `yaml:"rules"`
+}
+
+// expressionRule is a CEL expression every entity matched by its selector
+// must satisfy, with the message reported when it doesn't.
+type expressionRule struct {
+	ruleHeader `yaml:",inline"`
+	Expression string `yaml:"expression"`
+	// Message is a Go template executed with the entity.
+	Message string `yaml:"message"`
+
+	program cel.Program
+	message *template.Template
+}
+
+// ruleEvaluator evaluates expression rules, resolving the entities looked up
+// by the expressions from the catalog, each of them once.
+type ruleEvaluator struct {
+	// ctx is the context the entities are looked up with, the one of the
+	// command once authenticated, bounded by --timeout.
+	ctx      context.Context
+	env      *cel.Env
+	entities map[string]interface{}
+	// err is the first error met while looking up entities, which fails the
+	// command rather than the rule.
+	err error
+}
+
+func newRuleEvaluator() (*ruleEvaluator, error) {
+	e := &ruleEvaluator{ctx: context.Background(), entities: make(map[string]interface{})}
+
+	env, err := cel.NewEnv(
+		cel.Variable("entity", cel.MapType(cel.StringType, cel.DynType)),
+		cel.Function("lookup",
+			cel.Overload("lookup_string", []*cel.Type{cel.StringType}, cel.DynType,
+				cel.UnaryBinding(func(value ref.Val) ref.Val {
+					return e.lookup(fmt.Sprint(value.Value()), "")
+				})),
+			cel.Overload("lookup_string_string", []*cel.Type{cel.StringType, cel.StringType}, cel.DynType,
+				cel.BinaryBinding(func(value, kind ref.Val) ref.Val {
+					return e.lookup(fmt.Sprint(value.Value()), fmt.Sprint(kind.Value()))
+				})),
+		),
+		cel.Function("refs",
+			cel.Overload("refs_dyn_string", []*cel.Type{cel.DynType, cel.StringType}, cel.ListType(cel.StringType),
+				cel.BinaryBinding(func(entity, relationType ref.Val) ref.Val {
+					return types.DefaultTypeAdapter.NativeToValue(relationTargets(entity.Value(), fmt.Sprint(relationType.Value())))
+				})),
+		),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error creating the expression environment: %w", err)
+	}
+	e.env = env
+	return e, nil
+}
+
+// lookup returns the entity with the given ref as a document, or null when
+// the catalog doesn't have it. The kind defaults to kind when the ref has
+// none, the namespace to default.
+func (e *ruleEvaluator) lookup(value, kind string) ref.Val {
+	entityRef, err := catalog.ParseEntityRef(value, catalog.EntityRef{Kind: kind})
+	if err != nil {
+		return types.NewErr("lookup: %v", err)
+	}
+
+	key := entityRef.String()
+	if document, ok := e.entities[key]; ok {
+		return types.DefaultTypeAdapter.NativeToValue(document)
+	}
+
+	found, err := client.GetEntitiesByRefs(e.ctx, []string{key}, nil)
+	if err != nil {
+		if e.err == nil {
+			e.err = err
+		}
+		return types.NewErr("lookup: %v", err)
+	}
+	var document interface{}
+	if len(found) > 0 && found[0].Kind != "" {
+		if document, err = toDocument(found[0]); err != nil {
+			return types.NewErr("lookup: %v", err)
+		}
+	}
+	e.entities[key] = document
+	return types.DefaultTypeAdapter.NativeToValue(document)
+}
+
+// relationTargets returns the targets of the relations of the given type of
+// an entity document, none when it is null.
+func relationTargets(document interface{}, relationType string) []string {
+	targets := []string{}
+	entity, _ := document.(map[string]interface{})
+	relations, _ := entity["relations"].([]interface{})
+	for _, item := range relations {
+		relation, _ := item.(map[string]interface{})
+		if t, _ := relation["type"].(string); strings.EqualFold(t, relationType) {
+			if target, ok := relation["targetRef"].(string); ok {
+				targets = append(targets, target)
+			}
+		}
+	}
+	return targets
+}
+
+// loadRules reads the rules file and compiles its expressions and message
+// templates.
+func loadRules(filename string, e *ruleEvaluator) (*ruleSet, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("%w: error reading rules file: %w", errInvalidArgs, err)
+	}
+
+	var rules ruleSet
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("%w: error parsing rules file %s: %w", errInvalidArgs, filename, err)
+	}
+	if len(rules.Rules) == 0 {
+		return nil, fmt.Errorf("%w: rules file %s has no rules", errInvalidArgs, filename)
+	}
+
+	for i := range rules.Rules {
+		rule := &rules.Rules[i]
+		if err := rule.validate(i); err != nil {
+			return nil, err
+		}
+		if strings.TrimSpace(rule.Expression) == "" {
+			return nil, fmt.Errorf("%w: rule %s has no expression", errInvalidArgs, rule.ID)
+		}
+
+		ast, issues := e.env.Compile(rule.Expression)
+		if issues != nil && issues.Err() != nil {
+			return nil, fmt.Errorf("%w: rule %s: invalid expression: %w", errInvalidArgs, rule.ID, issues.Err())
+		}
+		if !ast.OutputType().IsExactType(cel.BoolType) && !ast.OutputType().IsExactType(cel.DynType) {
+			return nil, fmt.Errorf("%w: rule %s: expression must be a bool, not %s", errInvalidArgs, rule.ID, ast.OutputType())
+		}
+		if rule.program, err = e.env.Program(ast); err != nil {
+			return nil, fmt.Errorf("%w: rule %s: %w", errInvalidArgs, rule.ID, err)
+		}
+
+		message := rule.Message
+		if message == "" {
+			message = rule.Description
+		}
+		if message == "" {
+			message = "expression not satisfied: " + rule.Expression
+		}
+		if rule.message, err = template.New(rule.ID).Parse(message); err != nil {
+			return nil, fmt.Errorf("%w: rule %s: invalid message template: %w", errInvalidArgs, rule.ID, err)
+		}
+	}
+	return &rules, nil
+}
+
+// check returns the finding of the rule for the entity, if any. Expressions
+// failing to evaluate, e.g. reading a field the entity doesn't have, are
+// reported as findings too.
+func (r *expressionRule) check(entity catalog.Entity, document interface{}) (*checkFinding, error) {
+	if !r.Selector.matches(entity) {
+		return nil, nil
+	}
+
+	out, _, err := r.program.Eval(map[string]interface{}{"entity": document})
+	if err != nil {
+		finding := r.finding(entity, fmt.Sprintf("error evaluating expression: %v", err))
+		return &finding, nil
+	}
+	satisfied, ok := out.Value().(bool)
+	if !ok {
+		finding := r.finding(entity, fmt.Sprintf("expression returned %v, not a bool", out.Value()))
+		return &finding, nil
+	}
+	if satisfied {
+		return nil, nil
+	}
+
+	var message bytes.Buffer
+	if err := r.message.Execute(&message, document); err != nil {
+		return nil, fmt.Errorf("rule %s: error rendering message: %w", r.ID, err)
+	}
+	finding := r.finding(entity, strings.TrimSpace(message.String()))
+	return &finding, nil
+}
+
+var rulesCmd = &cobra.Command{
+	Use:   "rules --file <file> [kind|entityRef] [name]",
+	Short: "Entities breaking the expression rules of a file",
+	Long: `Entities breaking the expression rules of a file. Each rule is a CEL
+expression, evaluated with the entity as 'entity', that the entities matched
+by its selector must satisfy. Its message is a Go template executed with the
+entity:
+
+  rules:
+    - id: production-on-call
+      severity: error
+      selector:
+        kind: [Component]
+        lifecycle: [production]
+      expression: >
+        'pagerduty.com/service-id' in entity.metadata.annotations &&
+        refs(entity, 'partOf').exists(s, s.startsWith('system:') &&
+          refs(lookup(s), 'ownedBy') == refs(entity, 'ownedBy'))
+      message: >
+        {{ .metadata.name }} must be on call and part of a system owned by
+        {{ .spec.owner }}
+
+Besides the CEL standard library, expressions can use:
+
+  lookup(ref)          the entity with the given ref, null if it doesn't exist
+  lookup(ref, kind)    the same, with kind as the default kind of the ref
+  refs(entity, type)   the target refs of the relations of the given type
+
+The command fails with exit code 7 when a finding is at least as severe as
+--fail-on.`,
+	Args: usageArgs(cobra.MaximumNArgs(2)),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		rulesFile, _ := cmd.Flags().GetString("file")
+		failOn, _ := cmd.Flags().GetString("fail-on")
+		outputFormat, err := getOutputFormat(cmd)
+		if err != nil {
+			return err
+		}
+
+		if rulesFile == "" {
+			return fmt.Errorf("%w: no rules file provided, please specify one with --file", errInvalidArgs)
+		}
+		if failOn, err = parseFailOn(failOn); err != nil {
+			return err
+		}
+		evaluator, err := newRuleEvaluator()
+		if err != nil {
+			return err
+		}
+		rules, err := loadRules(rulesFile, evaluator)
+		if err != nil {
+			return err
+		}
+
+		if err := initAuth(cmd); err != nil {
+			return err
+		}
+		evaluator.ctx = cmd.Context()
+
+		filter, err := parseArgs(cmd.Context(), args)
+		if err != nil {
+			return err
+		}
+		if len(args) == 0 {
+			filter = append(filter, kindsFilter(rules.Rules)...)
+		}
+
+		// Expressions may read any field of the entities
+		entities, err := client.QueryEntities(cmd.Context(), catalog.Query{Filter: filter})
+		if err != nil {
+			return err
+		}
+
+		var findings []checkFinding
+		for _, entity := range entities {
+			document, err := toDocument(entity)
+			if err != nil {
+				return err
+			}
+			for i := range rules.Rules {
+				finding, err := rules.Rules[i].check(entity, document)
+				if err != nil {
+					return err
+				}
+				if evaluator.err != nil {
+					return evaluator.err
+				}
+				if finding != nil {
+					findings = append(findings, *finding)
+				}
+			}
+		}
+
+		return printFindings(findings, outputFormat, failOn)
+	},
+}
+
+func init() {
+	rulesCmd.Flags().StringP("file", "f", "", "Rules file declaring the expressions")
+	rulesCmd.Flags().String("fail-on", "error", fmt.Sprintf("Lowest severity of the findings failing the command [%s|none]", strings.Join(severities, "|")))
+	addFindingsOutputFlag(rulesCmd)
+	checkCmd.AddCommand(rulesCmd)
+}
@@ -0,0 +1,288 @@
+package cmd
+
+import (
+	"fmt"
+	"iter"
+	"strings"
+
+	"github.com/vcaldaralo/backstagectl/catalog"
+)
+
+// requirement is a single condition of a label or field selector.
+type requirement struct {
+	path   string // dotted path of the field, e.g. metadata.labels.tier
+	op     string // =, !=, in, notin, exists or !exists
+	values []string
+}
+
+// selector is a list of requirements that must all match. The ones the
+// catalog can express are sent as filter conditions, and every requirement
+// is checked again on the returned entities, since the catalog matches
+// conditions on the same key if any of them does.
+type selector []requirement
+
+// parseLabelSelector parses a kubectl style label selector, e.g.
+// tier=gold,env!=dev,region in (eu,us),!deprecated.
+func parseLabelSelector(s string) (selector, error) {
+	var sel selector
+	for _, term := range splitSelector(s) {
+		r, err := parseLabelRequirement(term)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid selector '%s': %w", errInvalidArgs, term, err)
+		}
+		r.path = "metadata.labels." + r.path
+		sel = append(sel, r)
+	}
+	return sel, nil
+}
+
+func parseLabelRequirement(term string) (requirement, error) {
+	if key, found := strings.CutPrefix(term, "!"); found {
+		return requirement{path: strings.TrimSpace(key), op: "!exists"}, checkKey(key)
+	}
+
+	for _, op := range []string{" notin ", " in "} {
+		key, set, found := strings.Cut(term, op)
+		if !found {
+			continue
+		}
+		set = strings.TrimSpace(set)
+		if !strings.HasPrefix(set, "(") || !strings.HasSuffix(set, ")") {
+			return requirement{}, fmt.Errorf("values of %s must be in parentheses", strings.TrimSpace(op))
+		}
+		var values []string
+		for _, value := range strings.Split(set[1:len(set)-1], ",") {
+			if value = strings.TrimSpace(value); value != "" {
+				values = append(values, value)
+			}
+		}
+		if len(values) == 0 {
+			return requirement{}, fmt.Errorf("%s needs at least one value", strings.TrimSpace(op))
+		}
+		return requirement{path: strings.TrimSpace(key), op: strings.TrimSpace(op), values: values}, checkKey(key)
+	}
+
+	if r, found := parseEquality(term); found {
+		return r, checkKey(r.path)
+	}
+	return requirement{path: term, op: "exists"}, checkKey(term)
+}
+
+// parseFieldSelector parses a selector on dotted paths of the entity, e.g.
+// spec.lifecycle=production,spec.owner!=group:team-a.
+func parseFieldSelector(s string) (selector, error) {
+	var sel selector
+	for _, term := range splitSelector(s) {
+		r, found := parseEquality(term)
+		if !found {
+			return nil, fmt.Errorf("%w: invalid field selector '%s': expected field=value, field==value or field!=value", errInvalidArgs, term)
+		}
+		if err := checkKey(r.path); err != nil {
+			return nil, fmt.Errorf("%w: invalid field selector '%s': %w", errInvalidArgs, term, err)
+		}
+		sel = append(sel, r)
+	}
+	return sel, nil
+}
+
+// parseEquality parses key=value, key==value or key!=value.
+func parseEquality(term string) (requirement, bool) {
+	for _, op := range []string{"!=", "==", "="} {
+		if key, value, found := strings.Cut(term, op); found {
+			if op == "==" {
+				op = "="
+			}
+			return requirement{path: strings.TrimSpace(key), op: op, values: []string{strings.TrimSpace(value)}}, true
+		}
+	}
+	return requirement{}, false
+}
+
+func checkKey(key string) error {
+	key = strings.TrimSpace(key)
+	if key == "" {
+		return fmt.Errorf("missing key")
+	}
+	if strings.ContainsAny(key, " \t()") {
+		return fmt.Errorf("invalid key '%s'", key)
+	}
+	return nil
+}
+
+// splitSelector splits a selector on the commas outside of parentheses.
+func splitSelector(s string) []string {
+	var terms []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	terms = append(terms, s[start:])
+
+	var nonEmpty []string
+	for _, term := range terms {
+		if term = strings.TrimSpace(term); term != "" {
+			nonEmpty = append(nonEmpty, term)
+		}
+	}
+	return nonEmpty
+}
+
+// filter returns the filter conditions for the requirements the catalog can
+// express. Negations are only checked by matches.
+func (s selector) filter() []string {
+	var filter []string
+	for _, r := range s {
+		switch r.op {
+		case "=", "in":
+			for _, value := range r.values {
+				filter = append(filter, fmt.Sprintf("%s=%s", r.path, value))
+			}
+		case "exists":
+			filter = append(filter, r.path)
+		}
+	}
+	return filter
+}
+
+// clientSide reports whether some requirements can't be expressed as catalog
+// filters and are only checked by matches.
+func (s selector) clientSide() bool {
+	for _, r := range s {
+		switch r.op {
+		case "!=", "notin", "!exists":
+			return true
+		}
+	}
+	return false
+}
+
+// fields returns the entity fields read by matches, at most two levels deep
+// since label and annotation keys may contain dots.
+func (s selector) fields() []string {
+	var fields []string
+	for _, r := range s {
+		segments := strings.SplitN(r.path, ".", 3)
+		fields = append(fields, strings.Join(segments[:min(len(segments), 2)], "."))
+	}
+	return fields
+}
+
+// matches reports whether the entity meets every requirement. Values are
+// compared case insensitively, and a requirement on a list matches if any of
+// its items does, like catalog filters.
+func (s selector) matches(entity catalog.Entity) (bool, error) {
+	if len(s) == 0 {
+		return true, nil
+	}
+	document, err := toDocument(entity)
+	if err != nil {
+		return false, err
+	}
+
+	for _, r := range s {
+		found := lookupField(document, strings.Split(r.path, "."))
+		var ok bool
+		switch r.op {
+		case "=", "in":
+			ok = anyEqual(found, r.values)
+		case "!=", "notin":
+			ok = !anyEqual(found, r.values)
+		case "exists":
+			ok = len(found) > 0
+		case "!exists":
+			ok = len(found) == 0
+		}
+		if !ok {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// filterEntities returns an iterator over the entities matching the
+// selector. Errors are passed along and end the iteration.
+func (s selector) filterEntities(entities iter.Seq2[catalog.Entity, error]) iter.Seq2[catalog.Entity, error] {
+	return func(yield func(catalog.Entity, error) bool) {
+		for entity, err := range entities {
+			if err == nil {
+				var ok bool
+				if ok, err = s.matches(entity); err == nil && !ok {
+					continue
+				}
+			}
+			if !yield(entity, err) || err != nil {
+				return
+			}
+		}
+	}
+}
+
+// limitEntities returns an iterator over the first limit entities, or all of
+// them when limit is 0.
+func limitEntities(entities iter.Seq2[catalog.Entity, error], limit int) iter.Seq2[catalog.Entity, error] {
+	if limit == 0 {
+		return entities
+	}
+	return func(yield func(catalog.Entity, error) bool) {
+		read := 0
+		for entity, err := range entities {
+			if !yield(entity, err) || err != nil {
+				return
+			}
+			if read++; read >= limit {
+				return
+			}
+		}
+	}
+}
+
+// lookupField returns the values found at the path segments, ignoring case
+// in keys. Keys may contain dots, e.g. metadata.annotations.backstage.io/orphan,
+// and lists are searched item by item.
+func lookupField(value interface{}, segments []string) []interface{} {
+	if len(segments) == 0 {
+		if list, ok := value.([]interface{}); ok {
+			return list
+		}
+		return []interface{}{value}
+	}
+
+	var found []interface{}
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for i := len(segments); i > 0; i-- {
+			key := strings.Join(segments[:i], ".")
+			for name, child := range v {
+				if strings.EqualFold(name, key) {
+					found = append(found, lookupField(child, segments[i:])...)
+				}
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			found = append(found, lookupField(item, segments)...)
+		}
+	}
+	return found
+}
+
+func anyEqual(found []interface{}, values []string) bool {
+	for _, value := range found {
+		for _, want := range values {
+			if strings.EqualFold(formatValue(value), want) {
+				return true
+			}
+		}
+	}
+	return false
+}